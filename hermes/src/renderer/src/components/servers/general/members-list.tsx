@@ -3,7 +3,7 @@ import { User, Crown } from 'lucide-react'
 interface Member {
   server_id: string
   user_id: string
-  role: string
+  is_owner: boolean
   nickname?: string
   user: {
     id: string
@@ -39,7 +39,7 @@ export default function MembersList({ members }: MembersListProps) {
       <div className="space-y-[2px]">
         {members.map((member) => {
           const displayName = member.nickname || member.user.display_name
-          const isOwner = member.role === 'owner'
+          const isOwner = member.is_owner
 
           return (
             <div
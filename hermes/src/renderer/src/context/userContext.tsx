@@ -16,7 +16,6 @@ export interface UpdateProfilePayload {
   username?: string
   email?: string
   displayName?: string
-  avatarUrl?: string
   status?: string
 }
 
@@ -46,7 +45,6 @@ const mapToSnakeCase = (updates: UpdateProfilePayload): Record<string, any> => {
   if (updates.username !== undefined) payload.username = updates.username
   if (updates.email !== undefined) payload.email = updates.email
   if (updates.displayName !== undefined) payload.display_name = updates.displayName
-  if (updates.avatarUrl !== undefined) payload.avatar_url = updates.avatarUrl
   if (updates.status !== undefined) payload.status = updates.status
 
   return payload
@@ -42,7 +42,7 @@ export const useChat = (serverId: string, channelId: string) => {
       setIsLoadingHistory(true)
       try {
         const response = await api.get(`/servers/${serverId}/channels/${channelId}/messages`)
-        setMessages(response.data || [])
+        setMessages(response.data?.messages || [])
       } catch (error) {
         console.error('Failed to load chat history:', error)
       } finally {
@@ -13,7 +13,7 @@ export interface User {
 export interface ServerMember {
   server_id: string
   user_id: string
-  role: string
+  is_owner: boolean
   nickname?: string
   user: User
   joined_at: string
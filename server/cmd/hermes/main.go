@@ -1,6 +1,8 @@
 package main
 
 import (
+	"log"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 
@@ -8,6 +10,10 @@ import (
 	"github.com/jonahgcarpenter/hermes/server/internal/controllers"
 	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/middleware"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/ratelimit"
+	"github.com/jonahgcarpenter/hermes/server/internal/storage"
+	"github.com/jonahgcarpenter/hermes/server/internal/unfurl"
 	"github.com/jonahgcarpenter/hermes/server/internal/utils"
 	"github.com/jonahgcarpenter/hermes/server/internal/webrtc"
 	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
@@ -16,24 +22,43 @@ import (
 func main() {
 	cfg := config.Load()
 
-	database.Connect(cfg)
-
 	// NOTE: This number represents server ID
 	// Hardcoding "1" is fine until scaled
 	utils.InitIDGenerator(1)
 
+	database.Connect(cfg)
+
+	if err := storage.Init(cfg); err != nil {
+		log.Fatalf("Failed to set up file storage: %v", err)
+	}
+
+	// Link previews are fetched in the background
+	unfurl.Init(cfg)
+
+	// Per user send limits
+	ratelimit.Init(cfg)
+
 	// Set JWTSecret once instead of passing it every time
 	utils.InitJWT(cfg.JWTSecret)
 
 	// Websocket start
 	go websockets.Manager.Run()
 
+	// Hard delete soft deleted rows once they can no longer be restored
+	go database.RunPurger()
+
+	// Archive threads that have gone quiet
+	go controllers.RunThreadArchiver()
+
+	// Send scheduled messages when they come due
+	go controllers.RunScheduler()
+
 	r := gin.Default()
 
 	corsConfig := cors.DefaultConfig()
 	corsConfig.AllowOrigins = []string{"http://localhost:5173", "http://localhost:5174"}
 	corsConfig.AllowCredentials = true
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Length", "Content-Type", "Authorization", "X-Audit-Log-Reason"}
 	r.Use(cors.New(corsConfig))
 
 	api := r.Group("/api")
@@ -58,9 +83,53 @@ func main() {
 			userRoute.GET("/@me", controllers.GetCurrentUser)
 			userRoute.PATCH("/@me", controllers.UpdateCurrentUser)
 			userRoute.DELETE("/@me", controllers.DeleteCurrentUser)
+			userRoute.GET("/@me/scheduled-messages", controllers.ListMyScheduledMessages)
 			userRoute.GET("/:userID", controllers.GetUserProfile)
 		}
 
+		// Invites
+		inviteRoute := api.Group("/invites", middleware.AuthRequired())
+		{
+			inviteRoute.GET("/:code", controllers.ResolveInvite)
+			inviteRoute.POST("/:code", controllers.AcceptInvite)
+		}
+
+		// Attachments, access is checked against the channel they were sent in
+		api.GET("/attachments/:attachmentID/:filename", middleware.AuthRequired(), controllers.DownloadAttachment)
+
+		// Avatars and server icons, public so they work anywhere a URL does
+		api.GET("/images/:hash/:variant", controllers.ServeImage)
+
+		// Direct messages and group DMs, these live outside of any server
+		dmRoute := api.Group("/dms", middleware.AuthRequired())
+		{
+			dmRoute.GET("", controllers.ListDMChannels)
+			dmRoute.POST("", controllers.CreateDMChannel)
+
+			singleDMRoute := dmRoute.Group("/:channelID", middleware.RequireDMRecipient())
+			{
+				singleDMRoute.PUT("/recipients/:userID", controllers.AddDMRecipient)
+				singleDMRoute.DELETE("/recipients/:userID", controllers.RemoveDMRecipient)
+
+				// Same handlers as server channels, verifyChannel tells them apart
+				singleDMRoute.GET("/messages", controllers.ListMessages)
+				singleDMRoute.POST("/messages", controllers.SendMessage)
+				singleDMRoute.PATCH("/messages/:messageID", controllers.EditMessage)
+				singleDMRoute.DELETE("/messages/:messageID", controllers.DeleteMessage)
+				singleDMRoute.POST("/messages/:messageID/ack", controllers.AckMessage)
+				singleDMRoute.GET("/pins", controllers.ListPins)
+				singleDMRoute.PUT("/pins/:messageID", controllers.PinMessage)
+				singleDMRoute.DELETE("/pins/:messageID", controllers.UnpinMessage)
+				singleDMRoute.GET("/scheduled-messages", controllers.ListScheduledMessages)
+				singleDMRoute.POST("/scheduled-messages", controllers.CreateScheduledMessage)
+				singleDMRoute.PATCH("/scheduled-messages/:scheduleID", controllers.UpdateScheduledMessage)
+				singleDMRoute.DELETE("/scheduled-messages/:scheduleID", controllers.DeleteScheduledMessage)
+				singleDMRoute.GET("/messages/:messageID/reactions/:emoji", controllers.ListReactionUsers)
+				singleDMRoute.PUT("/messages/:messageID/reactions/:emoji/@me", controllers.AddReaction)
+				singleDMRoute.DELETE("/messages/:messageID/reactions/:emoji/:userID", controllers.RemoveReaction)
+			}
+		}
+
 		// Servers
 		serverRoute := api.Group("/servers", middleware.AuthRequired())
 		{
@@ -73,30 +142,118 @@ func main() {
 				singleServerRoute.GET("", controllers.ServerDetails)
 				singleServerRoute.GET("/members", middleware.RequireMembership(), controllers.ListServerMembers)
 				singleServerRoute.DELETE("/leave", middleware.RequireMembership(), controllers.LeaveServer)
-				singleServerRoute.PATCH("", middleware.RequirePermission("manage_server"), controllers.UpdateServer)
-				singleServerRoute.DELETE("", middleware.RequirePermission("manage_server"), controllers.DeleteServer)
+				singleServerRoute.PATCH("", middleware.RequirePermission(models.PermissionManageServer), controllers.UpdateServer)
+				singleServerRoute.DELETE("", middleware.RequirePermission(models.PermissionManageServer), controllers.DeleteServer)
+				singleServerRoute.POST("/restore", controllers.RestoreServer)
+				singleServerRoute.POST("/transfer-ownership", middleware.RequireMembership(), controllers.TransferOwnership)
+				singleServerRoute.GET("/audit-logs", middleware.RequirePermission(models.PermissionViewAuditLog), controllers.ListAuditLogs)
+				singleServerRoute.GET("/messages/search", middleware.RequireMembership(), controllers.SearchMessages)
+
+				// Invites
+				inviteRoute := singleServerRoute.Group("/invites")
+				{
+					inviteRoute.GET("", middleware.RequirePermission(models.PermissionManageServer), controllers.ListInvites)
+					inviteRoute.POST("", middleware.RequirePermission(models.PermissionCreateInvite), controllers.CreateInvite)
+					inviteRoute.DELETE("/:code", middleware.RequirePermission(models.PermissionManageServer), controllers.RevokeInvite)
+				}
+
+				// Custom emoji
+				emojiRoute := singleServerRoute.Group("/emojis", middleware.RequireMembership())
+				{
+					emojiRoute.GET("", controllers.ListEmojis)
+					emojiRoute.POST("", middleware.RequirePermission(models.PermissionManageServer), controllers.CreateEmoji)
+					emojiRoute.DELETE("/:emojiID", middleware.RequirePermission(models.PermissionManageServer), controllers.DeleteEmoji)
+				}
+
+				// Roles
+				roleRoute := singleServerRoute.Group("/roles", middleware.RequireMembership())
+				{
+					roleRoute.GET("", controllers.ListRoles)
+					roleRoute.POST("", middleware.RequirePermission(models.PermissionManageRoles), controllers.CreateRole)
+					roleRoute.PATCH("/:roleID", middleware.RequirePermission(models.PermissionManageRoles), controllers.UpdateRole)
+					roleRoute.DELETE("/:roleID", middleware.RequirePermission(models.PermissionManageRoles), controllers.DeleteRole)
+				}
+				singleServerRoute.PUT("/members/:userID/roles/:roleID", middleware.RequirePermission(models.PermissionManageRoles), controllers.AddMemberRole)
+				singleServerRoute.DELETE("/members/:userID/roles/:roleID", middleware.RequirePermission(models.PermissionManageRoles), controllers.RemoveMemberRole)
+
+				// Moderation
+				singleServerRoute.DELETE("/members/:userID", middleware.RequirePermission(models.PermissionKickMembers), controllers.KickMember)
+				singleServerRoute.PUT("/members/:userID/timeout", middleware.RequirePermission(models.PermissionModerateMembers), controllers.TimeoutMember)
+				banRoute := singleServerRoute.Group("/bans", middleware.RequirePermission(models.PermissionBanMembers))
+				{
+					banRoute.GET("", controllers.ListBans)
+					banRoute.PUT("/:userID", controllers.BanMember)
+					banRoute.DELETE("/:userID", controllers.UnbanMember)
+				}
 
 				// Channels
 				channelRoute := singleServerRoute.Group("/channels", middleware.RequireMembership())
 				{
 					channelRoute.GET("", controllers.ListChannels)
-					channelRoute.POST("", middleware.RequirePermission("manage_channels"), controllers.CreateChannel)
-					channelRoute.PATCH("/:channelID", middleware.RequirePermission("manage_channels"), controllers.UpdateChannel)
-					channelRoute.DELETE("/:channelID", middleware.RequirePermission("manage_channels"), controllers.DeleteChannel)
+					channelRoute.POST("", middleware.RequirePermission(models.PermissionManageChannels), controllers.CreateChannel)
+					channelRoute.PATCH("", middleware.RequirePermission(models.PermissionManageChannels), controllers.ReorderChannels)
+					channelRoute.PATCH("/:channelID", middleware.RequirePermission(models.PermissionManageChannels), controllers.UpdateChannel)
+					channelRoute.DELETE("/:channelID", middleware.RequirePermission(models.PermissionManageChannels), controllers.DeleteChannel)
+					channelRoute.POST("/:channelID/restore", middleware.RequirePermission(models.PermissionManageChannels), controllers.RestoreChannel)
+					channelRoute.PUT("/:channelID/permissions/:targetID", middleware.RequirePermission(models.PermissionManageRoles), controllers.UpsertChannelOverwrite)
+					channelRoute.DELETE("/:channelID/permissions/:targetID", middleware.RequirePermission(models.PermissionManageRoles), controllers.DeleteChannelOverwrite)
 
 					// Messages
 					messageRoute := channelRoute.Group("/:channelID/messages")
 					{
-						messageRoute.GET("", controllers.ListMessages)
-						messageRoute.POST("", controllers.SendMessage)
-						messageRoute.PATCH("/:messageID", controllers.EditMessage)
-						messageRoute.DELETE("/:messageID", controllers.DeleteMessage)
+						messageRoute.GET("", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListMessages)
+						messageRoute.POST("", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.SendMessage)
+						messageRoute.POST("/bulk-delete", middleware.RequireChannelPermission(models.PermissionManageMessages), controllers.BulkDeleteMessages)
+						messageRoute.PATCH("/:messageID", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.EditMessage)
+						messageRoute.DELETE("/:messageID", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.DeleteMessage)
+						messageRoute.GET("/:messageID/revisions", middleware.RequireChannelPermission(models.PermissionManageMessages), controllers.ListMessageRevisions)
+						messageRoute.POST("/:messageID/restore", middleware.RequireChannelPermission(models.PermissionManageMessages), controllers.RestoreMessage)
+						messageRoute.POST("/:messageID/ack", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.AckMessage)
+						messageRoute.POST("/:messageID/threads", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.CreateThread)
+						messageRoute.POST("/:messageID/publish", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.PublishMessage)
+
+						// Reactions, userID can be @me to remove your own
+						messageRoute.GET("/:messageID/reactions/:emoji", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListReactionUsers)
+						messageRoute.PUT("/:messageID/reactions/:emoji/@me", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.AddReaction)
+						messageRoute.DELETE("/:messageID/reactions/:emoji/:userID", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.RemoveReaction)
 					}
 
+					// Pins
+					channelRoute.GET("/:channelID/pins", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListPins)
+					channelRoute.PUT("/:channelID/pins/:messageID", middleware.RequireChannelPermission(models.PermissionManageMessages), controllers.PinMessage)
+					channelRoute.DELETE("/:channelID/pins/:messageID", middleware.RequireChannelPermission(models.PermissionManageMessages), controllers.UnpinMessage)
+
+					// Scheduled messages, each member only sees their own
+					channelRoute.GET("/:channelID/scheduled-messages", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListScheduledMessages)
+					channelRoute.POST("/:channelID/scheduled-messages", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.CreateScheduledMessage)
+					channelRoute.PATCH("/:channelID/scheduled-messages/:scheduleID", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.UpdateScheduledMessage)
+					channelRoute.DELETE("/:channelID/scheduled-messages/:scheduleID", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.DeleteScheduledMessage)
+
+					// Announcements, followers live on the announcement channel and follows on the channel receiving copies
+					channelRoute.POST("/:channelID/followers", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.FollowChannel)
+					channelRoute.GET("/:channelID/followers", middleware.RequireChannelPermission(models.PermissionManageChannels), controllers.ListChannelFollowers)
+					channelRoute.DELETE("/:channelID/followers/:followID", middleware.RequireChannelPermission(models.PermissionManageChannels), controllers.DeleteChannelFollower)
+					channelRoute.GET("/:channelID/follows", middleware.RequireChannelPermission(models.PermissionManageWebhooks), controllers.ListChannelFollows)
+					channelRoute.DELETE("/:channelID/follows/:followID", middleware.RequireChannelPermission(models.PermissionManageWebhooks), controllers.DeleteChannelFollow)
+
+					// Threads, these are channels too so their messages use the routes above
+					channelRoute.GET("/:channelID/threads", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListThreads)
+					channelRoute.PATCH("/:channelID/thread", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.UpdateThread)
+					channelRoute.GET("/:channelID/thread-members", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListThreadMembers)
+					channelRoute.PUT("/:channelID/thread-members/@me", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.JoinThread)
+					channelRoute.DELETE("/:channelID/thread-members/@me", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.LeaveThread)
+
+					// Forums, each post is a thread so its messages and settings use the routes above
+					channelRoute.GET("/:channelID/posts", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.ListForumPosts)
+					channelRoute.POST("/:channelID/posts", middleware.RequireChannelPermission(models.PermissionSendMessages), controllers.CreateForumPost)
+					channelRoute.POST("/:channelID/tags", middleware.RequireChannelPermission(models.PermissionManageChannels), controllers.CreateForumTag)
+					channelRoute.PATCH("/:channelID/tags/:tagID", middleware.RequireChannelPermission(models.PermissionManageChannels), controllers.UpdateForumTag)
+					channelRoute.DELETE("/:channelID/tags/:tagID", middleware.RequireChannelPermission(models.PermissionManageChannels), controllers.DeleteForumTag)
+
 					// Voice
 					voiceRoute := channelRoute.Group("/:channelID/voice")
 					{
-						voiceRoute.GET("/members", controllers.VoiceMembers)
+						voiceRoute.GET("/members", middleware.RequireChannelPermission(models.PermissionViewChannels), controllers.VoiceMembers)
 					}
 				}
 			}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +14,33 @@ type Config struct {
 	Port        string
 	DatabaseURL string
 	JWTSecret   string
+
+	// Where clients reach this server, links to avatars and icons are built on it
+	PublicURL string
+
+	// How long soft deleted servers, channels and messages can be restored before they are purged
+	DeletedRetention time.Duration
+
+	// Where uploads are kept, "local" or "s3"
+	StorageDriver string
+	StoragePath   string // Local only
+	S3Endpoint    string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3Bucket      string
+	S3Region      string
+	S3UseSSL      bool
+
+	// Upload limits, in bytes
+	MaxUploadSize     int64 // Per file
+	ServerUploadQuota int64 // Total attachments per server
+
+	// Link previews are fetched by this many background workers, 0 turns them off
+	UnfurlWorkers int
+
+	// Per user token bucket for sending messages and websocket events
+	RateLimitBurst     int
+	RateLimitPerMinute int
 }
 
 func Load() *Config {
@@ -19,10 +48,32 @@ func Load() *Config {
 		log.Println("Info: No .env file found, relying on default/system variables")
 	}
 
+	port := getEnv("PORT", "8080")
+
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
+		Port:        port,
 		DatabaseURL: getEnv("DATABASE_URL", ""),                                // SQLite when not set
 		JWTSecret:   getEnv("JWT_SECRET", "super-secure-secret-please-change"), // openssl rand -base64 32
+		PublicURL:   strings.TrimRight(getEnv("PUBLIC_URL", "http://localhost:"+port), "/"),
+
+		DeletedRetention: time.Duration(getEnvInt("DELETED_RETENTION_DAYS", 30)) * 24 * time.Hour,
+
+		StorageDriver: getEnv("STORAGE_DRIVER", "local"),
+		StoragePath:   getEnv("STORAGE_PATH", "./uploads"),
+		S3Endpoint:    getEnv("S3_ENDPOINT", "localhost:9000"),
+		S3AccessKey:   getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:   getEnv("S3_SECRET_KEY", ""),
+		S3Bucket:      getEnv("S3_BUCKET", "hermes"),
+		S3Region:      getEnv("S3_REGION", "us-east-1"),
+		S3UseSSL:      getEnvBool("S3_USE_SSL", false),
+
+		MaxUploadSize:     int64(getEnvInt("MAX_UPLOAD_SIZE_MB", 25)) << 20,
+		ServerUploadQuota: int64(getEnvInt("SERVER_UPLOAD_QUOTA_MB", 1024)) << 20,
+
+		UnfurlWorkers: getEnvInt("UNFURL_WORKERS", 4),
+
+		RateLimitBurst:     max(getEnvInt("RATE_LIMIT_BURST", 5), 1),
+		RateLimitPerMinute: max(getEnvInt("RATE_LIMIT_PER_MINUTE", 60), 1),
 	}
 }
 
@@ -33,6 +84,16 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		intValue, err := strconv.Atoi(value)
+		if err == nil {
+			return intValue
+		}
+	}
+	return fallback
+}
+
 func getEnvBool(key string, fallback bool) bool {
 	if value, exists := os.LookupEnv(key); exists {
 		boolValue, err := strconv.ParseBool(value)
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+var errAlreadyPublished = errors.New("message already published")
+
+type FollowChannelPayload struct {
+	TargetChannelID uint64 `json:"target_channel_id,string" binding:"required"`
+}
+
+// FollowChannel links an announcement channel to a text channel in another server,
+// everything published from now on gets copied over there
+func FollowChannel(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelObj, _ := c.Get("channel")
+	source := channelObj.(models.Channel)
+
+	if source.Type != models.ChannelTypeAnnouncement {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only announcement channels can be followed"})
+		return
+	}
+
+	var payload FollowChannelPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// The target has to be a text channel the caller can see, in a server they belong to
+	var target models.Channel
+	if err := database.DB.Where("id = ? AND type = ?", payload.TargetChannelID, models.ChannelTypeText).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target channel not found"})
+		return
+	}
+	member, err := permissions.Resolve(*target.ServerID, userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target channel not found"})
+		return
+	}
+	targetPerms, err := member.ForChannel(target.ID)
+	if err != nil || !targetPerms.Has(models.PermissionViewChannels) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target channel not found"})
+		return
+	}
+	if !targetPerms.Has(models.PermissionManageWebhooks) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You need permission to manage webhooks in the target channel"})
+		return
+	}
+
+	if *target.ServerID == serverID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Announcements can only be followed from another server"})
+		return
+	}
+
+	var existing models.ChannelFollower
+	if err := database.DB.Where("source_channel_id = ? AND target_channel_id = ?", source.ID, target.ID).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "That channel already follows this one"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.ChannelFollower{}).Where("target_channel_id = ?", target.ID).Count(&count)
+	if count >= models.MaxFollowsPerChannel {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A channel can follow at most " + strconv.Itoa(models.MaxFollowsPerChannel) + " announcement channels"})
+		return
+	}
+
+	follower := models.ChannelFollower{
+		ID:              utils.GenerateID(),
+		SourceChannelID: source.ID,
+		SourceServerID:  serverID,
+		TargetChannelID: target.ID,
+		TargetServerID:  *target.ServerID,
+		CreatedByID:     userID,
+	}
+	if err := database.DB.Create(&follower).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow channel"})
+		return
+	}
+
+	// Logged where the copies will show up, that's the server that has to live with them
+	writeAuditLog(c, follower.TargetServerID, models.AuditChannelFollowCreate, "channel", idString(target.ID), models.AuditChanges{
+		{Key: "source_channel_id", New: idString(source.ID)},
+		{Key: "source_server_id", New: idString(serverID)},
+	})
+
+	database.DB.Preload("SourceChannel").Preload("SourceServer").First(&follower, follower.ID)
+
+	c.JSON(http.StatusCreated, follower)
+}
+
+// ListChannelFollowers shows the announcement channel's side, every channel it sends copies to
+func ListChannelFollowers(c *gin.Context) {
+	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
+
+	followers := []models.ChannelFollower{}
+	if err := database.DB.Preload("TargetChannel").Preload("TargetServer").
+		Where("source_channel_id = ?", channelID).
+		Order("created_at asc").
+		Find(&followers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch followers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, followers)
+}
+
+// DeleteChannelFollower lets the announcement channel's moderators cut off a server following them
+func DeleteChannelFollower(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
+
+	var follower models.ChannelFollower
+	if err := database.DB.Where("id = ? AND source_channel_id = ?", c.Param("followID"), channelID).First(&follower).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follower not found"})
+		return
+	}
+
+	if err := database.DB.Delete(&follower).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove follower"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelFollowDelete, "channel", idString(channelID), models.AuditChanges{
+		{Key: "target_channel_id", Old: idString(follower.TargetChannelID)},
+		{Key: "target_server_id", Old: idString(follower.TargetServerID)},
+	})
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ListChannelFollows shows which announcement channels a text channel gets copies from
+func ListChannelFollows(c *gin.Context) {
+	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
+
+	follows := []models.ChannelFollower{}
+	if err := database.DB.Preload("SourceChannel").Preload("SourceServer").
+		Where("target_channel_id = ?", channelID).
+		Order("created_at asc").
+		Find(&follows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch followed channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, follows)
+}
+
+// DeleteChannelFollow stops copies coming into a channel. Already published copies stay.
+func DeleteChannelFollow(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
+
+	var follower models.ChannelFollower
+	if err := database.DB.Where("id = ? AND target_channel_id = ?", c.Param("followID"), channelID).First(&follower).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Follow not found"})
+		return
+	}
+
+	if err := database.DB.Delete(&follower).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow channel"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelFollowDelete, "channel", idString(channelID), models.AuditChanges{
+		{Key: "source_channel_id", Old: idString(follower.SourceChannelID)},
+		{Key: "source_server_id", Old: idString(follower.SourceServerID)},
+	})
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Builds the copy of an announcement that goes out to one follower channel. It's posted by
+// the source server rather than whoever wrote it, they're usually a stranger over there.
+// Mentions and replies only make sense in the source server, so they stay behind.
+func crosspostCopy(source models.Message, server models.Server, target models.Channel) models.Message {
+	message := models.Message{
+		ID:                  utils.GenerateID(),
+		ChannelID:           target.ID,
+		Content:             source.Content,
+		Embeds:              source.Embeds,
+		SourceMessageID:     &source.ID,
+		SourceChannelID:     &source.ChannelID,
+		SourceServerID:      &server.ID,
+		SourceServerName:    server.Name,
+		SourceServerIconURL: server.IconURL,
+	}
+
+	// The files themselves are shared, they only count towards the quota of the server that uploaded them
+	for _, attachment := range source.Attachments {
+		attachment.ID = utils.GenerateID()
+		attachment.MessageID = message.ID
+		attachment.ChannelID = target.ID
+		attachment.ServerID = nil
+		attachment.CreatedAt = time.Time{}
+		message.Attachments = append(message.Attachments, attachment)
+	}
+	return message
+}
+
+// PublishMessage copies an announcement into every channel following this one.
+// Authors can publish their own, anyone else needs to be able to manage messages.
+func PublishMessage(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelObj, _ := c.Get("channel")
+	channel := channelObj.(models.Channel)
+
+	if channel.Type != models.ChannelTypeAnnouncement {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only messages in announcement channels can be published"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	var message models.Message
+	if err := database.DB.Preload("Attachments").Where("id = ? AND channel_id = ?", messageID, channel.ID).First(&message).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+	perms := currentChannelPermissions(c)
+	if !(message.SentBy(userID) && perms.Has(models.PermissionSendMessages)) && !perms.Has(models.PermissionManageMessages) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only publish your own messages"})
+		return
+	}
+
+	if message.PublishedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This message has already been published"})
+		return
+	}
+
+	var server models.Server
+	if err := database.DB.First(&server, serverID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	// Followers whose channel has been deleted just miss out, the default scope skips them
+	var targetIDs []uint64
+	database.DB.Model(&models.ChannelFollower{}).Where("source_channel_id = ?", channel.ID).Pluck("target_channel_id", &targetIDs)
+	var targets []models.Channel
+	if len(targetIDs) > 0 {
+		if err := database.DB.Where("id IN ?", targetIDs).Find(&targets).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load followers"})
+			return
+		}
+	}
+
+	copies := make([]models.Message, 0, len(targets))
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Claims the publish, a second request racing this one finds nothing left to update
+		result := tx.Model(&models.Message{}).Where("id = ? AND published_at IS NULL", message.ID).Update("published_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyPublished
+		}
+
+		for _, target := range targets {
+			crosspost := crosspostCopy(message, server, target)
+			if err := tx.Create(&crosspost).Error; err != nil {
+				return err
+			}
+			copies = append(copies, crosspost)
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyPublished) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This message has already been published"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish message"})
+		return
+	}
+
+	for i, target := range targets {
+		crosspost := copies[i]
+		withMessageRelations(database.DB).First(&crosspost, crosspost.ID)
+		broadcastToChannel(*target.ServerID, target.ID, "MESSAGE_CREATE", crosspost)
+
+		// Published before its own previews were ready, the copy fetches them itself
+		if len(crosspost.Embeds) == 0 {
+			queueEmbeds(*target.ServerID, crosspost)
+		}
+	}
+
+	if err := withMessageRelations(database.DB).First(&message, message.ID).Error; err != nil {
+		log.Printf("Failed to reload published message %d: %v", message.ID, err)
+	}
+	broadcastToChannel(serverID, channel.ID, "MESSAGE_UPDATE", message)
+
+	c.JSON(http.StatusOK, message)
+}
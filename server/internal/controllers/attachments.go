@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/imaging"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/storage"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+const maxAttachmentsPerMessage = 10
+
+var (
+	errTooManyFiles  = errors.New("too many files")
+	errFileTooLarge  = errors.New("file too large")
+	errQuotaExceeded = errors.New("server upload quota exceeded")
+)
+
+// Content types browsers can show directly without any risk of running script
+var inlineContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"video/mp4":  true,
+	"video/webm": true,
+	"audio/mpeg": true,
+	"audio/ogg":  true,
+	"audio/wave": true,
+}
+
+// Turns an error from storeAttachments into a response
+func attachmentErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTooManyFiles):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Messages can have at most " + strconv.Itoa(maxAttachmentsPerMessage) + " attachments"})
+	case errors.Is(err, errFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Files can be at most " + strconv.FormatInt(storage.MaxFileSize>>20, 10) + " MB"})
+	case errors.Is(err, errQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "This server has run out of upload space"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload attachments"})
+	}
+}
+
+// Grabs the files sent with a multipart message, if any
+func uploadedFiles(c *gin.Context) []*multipart.FileHeader {
+	if c.ContentType() != "multipart/form-data" {
+		return nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil
+	}
+	return form.File["files"]
+}
+
+// Keeps the base name and drops anything that could confuse a header or a filesystem
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '/' || r == '\\' || r == '"' {
+			return -1
+		}
+		return r
+	}, filepath.Base(name))
+
+	if name == "" || name == "." {
+		name = "file"
+	}
+	if len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+// Checks the files against the limits, then puts them in storage.
+// Nothing is written to the database, the caller saves the returned rows with the message.
+func storeAttachments(files []*multipart.FileHeader, serverID uint64, channelID uint64, messageID uint64, uploaderID uint64) ([]models.Attachment, error) {
+	if len(files) > maxAttachmentsPerMessage {
+		return nil, errTooManyFiles
+	}
+
+	var total int64
+	for _, fh := range files {
+		if fh.Size > storage.MaxFileSize {
+			return nil, errFileTooLarge
+		}
+		total += fh.Size
+	}
+
+	// DMs don't belong to a server, so only the per file limit applies there
+	var serverRef *uint64
+	if serverID != 0 {
+		serverRef = &serverID
+
+		var used int64
+		database.DB.Model(&models.Attachment{}).Where("server_id = ?", serverID).
+			Select("COALESCE(SUM(size), 0)").Scan(&used)
+		if used+total > storage.ServerUploadQuota {
+			return nil, errQuotaExceeded
+		}
+	}
+
+	attachments := make([]models.Attachment, 0, len(files))
+	for _, fh := range files {
+		attachment, err := storeAttachment(fh, serverRef, channelID, messageID, uploaderID)
+		if err != nil {
+			removeStoredAttachments(attachments)
+			return nil, err
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+func storeAttachment(fh *multipart.FileHeader, serverID *uint64, channelID uint64, messageID uint64, uploaderID uint64) (models.Attachment, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return models.Attachment{}, err
+	}
+	defer file.Close()
+
+	attachment := models.Attachment{
+		ID:         utils.GenerateID(),
+		MessageID:  messageID,
+		ChannelID:  channelID,
+		ServerID:   serverID,
+		UploaderID: uploaderID,
+		Filename:   sanitizeFilename(fh.Filename),
+		Size:       fh.Size,
+	}
+	attachment.StorageKey = "attachments/" + strconv.FormatUint(channelID, 10) + "/" + strconv.FormatUint(attachment.ID, 10)
+
+	// Trust the bytes over whatever the client claimed
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	attachment.ContentType = http.DetectContentType(head[:n])
+	if attachment.ContentType == "application/octet-stream" {
+		if claimed := fh.Header.Get("Content-Type"); claimed != "" {
+			if mediaType, _, err := mime.ParseMediaType(claimed); err == nil && !strings.HasPrefix(mediaType, "text/") {
+				attachment.ContentType = mediaType
+			}
+		}
+	}
+
+	var preview image.Image
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return attachment, err
+		}
+		if config, _, err := image.DecodeConfig(file); err == nil {
+			attachment.Width = &config.Width
+			attachment.Height = &config.Height
+		}
+
+		// Images we can fully decode also get a thumbnail, and their size as displayed
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return attachment, err
+		}
+		if img, err := imaging.Decode(file); err == nil {
+			width, height := img.Bounds().Dx(), img.Bounds().Dy()
+			attachment.Width = &width
+			attachment.Height = &height
+			preview = img
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return attachment, err
+	}
+	var body io.Reader = file
+
+	// Photos carry EXIF, GPS coordinates included. Rather than pass that on to everyone in the
+	// channel, images are stored as the pixels we decoded, the same way avatars are.
+	// GIFs have no EXIF and re-encoding would cost them their animation, so they stay as sent.
+	if carriesImageMetadata(attachment.ContentType) {
+		if preview != nil {
+			data, err := reencodeImage(preview, attachment.ContentType)
+			if err != nil {
+				return attachment, err
+			}
+			body = bytes.NewReader(data)
+			attachment.Size = int64(len(data))
+		} else {
+			// Couldn't clean it, so it goes out as a plain download rather than an inline image
+			attachment.ContentType = "application/octet-stream"
+		}
+	}
+
+	if err := storage.Store.Put(context.Background(), attachment.StorageKey, body, attachment.Size, attachment.ContentType); err != nil {
+		return attachment, err
+	}
+
+	// Previews are nice to have, the upload still goes through without them
+	if preview != nil {
+		if hash, err := imaging.Blurhash(preview); err == nil {
+			attachment.Blurhash = hash
+		}
+		if key, err := storeThumbnail(attachment.StorageKey, preview); err == nil {
+			attachment.ThumbnailKey = key
+		} else {
+			log.Printf("Failed to store thumbnail for attachment %d: %v", attachment.ID, err)
+		}
+	}
+	return attachment, nil
+}
+
+// Formats that can hide EXIF or XMP alongside the pixels
+func carriesImageMetadata(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Writes decoded pixels back out in the format they came in, which drops any metadata
+func reencodeImage(img image.Image, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return imaging.EncodeJPEG(img)
+	case "image/png":
+		return imaging.EncodePNG(img)
+	default:
+		return imaging.EncodeWebP(img)
+	}
+}
+
+// Best effort cleanup when the message they belonged to never got saved
+func removeStoredAttachments(attachments []models.Attachment) {
+	for _, attachment := range attachments {
+		for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+			if key == "" {
+				continue
+			}
+			if err := storage.Store.Delete(context.Background(), key); err != nil {
+				log.Printf("Failed to remove orphaned attachment %s: %v", key, err)
+			}
+		}
+	}
+}
+
+// Checks whether a user can see a channel, wherever it lives
+func canViewChannel(userID uint64, channel models.Channel) bool {
+	if channel.IsDM() {
+		return permissions.IsDMRecipient(channel.ID, userID)
+	}
+	if channel.ServerID == nil {
+		return false
+	}
+	member, err := permissions.Resolve(*channel.ServerID, userID)
+	if err != nil {
+		return false
+	}
+	perms, err := member.ForChannel(channel.ID)
+	return err == nil && perms.Has(models.PermissionViewChannels)
+}
+
+func DownloadAttachment(c *gin.Context) {
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var attachment models.Attachment
+	if err := database.DB.First(&attachment, "id = ?", c.Param("attachmentID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	// Files on deleted messages and in hidden channels look the same as missing ones
+	var message models.Message
+	var channel models.Channel
+	if database.DB.Select("id").First(&message, attachment.MessageID).Error != nil ||
+		database.DB.First(&channel, attachment.ChannelID).Error != nil ||
+		!canViewChannel(userID, channel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		return
+	}
+
+	// ?thumbnail=true fetches the preview instead of the original
+	key, size, contentType := attachment.StorageKey, attachment.Size, attachment.ContentType
+	if c.Query("thumbnail") == "true" {
+		if attachment.ThumbnailKey == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment has no thumbnail"})
+			return
+		}
+		key, size, contentType = attachment.ThumbnailKey, -1, "image/webp"
+	}
+
+	reader, err := storage.Store.Get(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		}
+		return
+	}
+	defer reader.Close()
+
+	disposition := "attachment"
+	if inlineContentTypes[contentType] {
+		disposition = "inline"
+	}
+
+	c.DataFromReader(http.StatusOK, size, contentType, reader, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}),
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "private, max-age=86400",
+	})
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+// Clients send an optional, URL-encoded reason with any administrative request
+const auditReasonHeader = "X-Audit-Log-Reason"
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 100
+)
+
+// Reads the optional reason header sent with an administrative request
+func auditReason(c *gin.Context) string {
+	raw := c.GetHeader(auditReasonHeader)
+	if reason, err := url.QueryUnescape(raw); err == nil {
+		raw = reason
+	}
+
+	// Percent-encoding can smuggle in any bytes, Postgres refuses anything that isn't valid UTF-8
+	raw = strings.ToValidUTF8(raw, "\uFFFD")
+	if len(raw) > 512 {
+		// Cut on a character boundary, not in the middle of one
+		cut := 512
+		for cut > 0 && !utf8.RuneStart(raw[cut]) {
+			cut--
+		}
+		raw = raw[:cut]
+	}
+	return raw
+}
+
+// Records an administrative action. Failures are logged rather than returned,
+// the action itself has already happened by the time we get here.
+func writeAuditLog(c *gin.Context, serverID uint64, action models.AuditAction, targetType string, targetID string, changes models.AuditChanges) {
+	userIDObj, _ := c.Get("user_id")
+	actorID := userIDObj.(uint64)
+
+	entry := models.AuditLogEntry{
+		ID:         utils.GenerateID(),
+		ServerID:   serverID,
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		Reason:     auditReason(c),
+	}
+
+	if err := database.DB.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write audit log entry %s for server %d: %v", action, serverID, err)
+	}
+}
+
+// Builds a diff from the fields about to be written and their current values.
+// Keys whose value doesn't actually change are left out.
+func diffUpdates(before map[string]interface{}, updates map[string]interface{}) models.AuditChanges {
+	keys := make([]string, 0, len(updates))
+	for key := range updates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := models.AuditChanges{}
+	for _, key := range keys {
+		if reflect.DeepEqual(before[key], updates[key]) {
+			continue
+		}
+		changes = append(changes, models.AuditChange{Key: key, Old: before[key], New: updates[key]})
+	}
+	return changes
+}
+
+// Formats a snowflake the same way the JSON API does
+func idString(id uint64) string {
+	return strconv.FormatUint(id, 10)
+}
+
+// Same for IDs that can be missing, like a channel's category, which come out as nil
+func optionalIDString(id *uint64) interface{} {
+	if id == nil {
+		return nil
+	}
+	return idString(*id)
+}
+
+func ListAuditLogs(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	limit := defaultAuditLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxAuditLimit)})
+			return
+		}
+	}
+
+	query := database.DB.Preload("Actor").Where("server_id = ?", serverID)
+
+	// Optional filters
+	if raw := c.Query("user_id"); raw != "" {
+		actorID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+
+	// Entries are newest first, so paging goes backwards from a snowflake cursor
+	before, hasBefore, err := parseCursor(c, "before")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID format"})
+		return
+	}
+	if hasBefore {
+		query = query.Where("id < ?", before)
+	}
+
+	var entries []models.AuditLogEntry
+	if err := query.Order("id desc").Limit(limit + 1).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries":  entries,
+		"has_more": hasMore,
+	})
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/url"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +21,6 @@ type RegisterRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Password    string `json:"password" binding:"required,min=8"`
 	DisplayName string `json:"display_name" binding:"required,max=32"`
-	AvatarURL   string `json:"avatar_url"`
 }
 
 func Register(c *gin.Context) {
@@ -59,12 +57,8 @@ func Register(c *gin.Context) {
 	// Generate Snowflake ID
 	newID := utils.GenerateID()
 
-	// Fallback avatar
-	avatarToSave := strings.TrimSpace(req.AvatarURL)
-	if avatarToSave == "" {
-		encodedName := url.QueryEscape(req.DisplayName)
-		avatarToSave = fmt.Sprintf("https://api.dicebear.com/7.x/bottts/svg?seed=%s", encodedName)
-	}
+	// Everyone starts with a generated avatar until they upload their own
+	avatarToSave := defaultAvatarURL(newID)
 
 	// Construct the user model
 	user := models.User{
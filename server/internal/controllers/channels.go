@@ -1,13 +1,21 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
 	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
 	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
 )
 
 func ListChannels(c *gin.Context) {
@@ -22,19 +30,223 @@ func ListChannels(c *gin.Context) {
 	// Fetch all channels belonging to this server.
 	// Order("position asc, name asc"): First sorts by their UI order (0, 1, 2, 3...).
 	// If two channels have the same position, it breaks the tie alphabetically by name.
-	if err := database.DB.Where("server_id = ?", serverID).
+	// Threads are listed per channel through ListThreads instead.
+	if err := withForumTags(database.DB).Preload("Overwrites").
+		Where("server_id = ? AND type <> ?", serverID, models.ChannelTypeThread).
 		Order("position asc, name asc").
 		Find(&channels).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch channels"})
 		return
 	}
 
-	c.JSON(http.StatusOK, channels)
+	// Only return the channels this member is allowed to see
+	member := currentMember(c)
+	visible := []models.Channel{}
+	for _, channel := range channels {
+		if member.InChannel(channel.Overwrites).Has(models.PermissionViewChannels) {
+			visible = append(visible, channel)
+		}
+	}
+
+	if err := attachReadStates(visible, member.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch read states"})
+		return
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+var (
+	errChannelNotFound   = errors.New("channel not found")
+	errDuplicateChannel  = errors.New("channel listed more than once")
+	errInvalidParent     = errors.New("parent is not a category in this server")
+	errCategoryHasParent = errors.New("categories cannot be nested")
+	errConflictingParent = errors.New("parent_id and remove_parent both set")
+)
+
+// Turns an error from layoutChannels into a response
+func channelLayoutErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errChannelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+	case errors.Is(err, errDuplicateChannel):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Each channel can only be moved once per request"})
+	case errors.Is(err, errInvalidParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent must be a category in this server"})
+	case errors.Is(err, errCategoryHasParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Categories cannot be put inside another category"})
+	case errors.Is(err, errConflictingParent):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either parent_id or remove_parent, not both"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel"})
+	}
+}
+
+// ChannelPositionPayload moves one channel. Anything left out stays as it is.
+type ChannelPositionPayload struct {
+	ID           uint64  `json:"id,string" binding:"required"`
+	Position     *int    `json:"position" binding:"omitempty,min=0"`
+	ParentID     *uint64 `json:"parent_id,string"`
+	RemoveParent bool    `json:"remove_parent"` // Takes it out of its category
+}
+
+// A channel whose position or parent changed during a layout
+type channelMove struct {
+	Before, After models.Channel
+	Requested     bool // false when it only shifted to make room
+}
+
+// Applies a batch of moves to a server's channels, then numbers every channel from 0 so no two share a position.
+// A position is the index the channel ends up at, everything that wasn't given one keeps its order around them.
+func layoutChannels(tx *gorm.DB, serverID uint64, moves []ChannelPositionPayload) ([]channelMove, error) {
+	var channels []models.Channel
+	if err := tx.Where("server_id = ? AND type <> ?", serverID, models.ChannelTypeThread).
+		Order("position asc, name asc").
+		Find(&channels).Error; err != nil {
+		return nil, err
+	}
+
+	indexByID := make(map[uint64]int, len(channels))
+	for i, channel := range channels {
+		indexByID[channel.ID] = i
+	}
+
+	// Work on a copy so the originals are left to compare against
+	next := make([]models.Channel, len(channels))
+	copy(next, channels)
+	requested := make(map[uint64]bool, len(moves))
+	positioned := make(map[uint64]bool, len(moves))
+	for _, move := range moves {
+		i, ok := indexByID[move.ID]
+		if !ok {
+			return nil, errChannelNotFound
+		}
+		if requested[move.ID] {
+			return nil, errDuplicateChannel
+		}
+		requested[move.ID] = true
+
+		if move.Position != nil {
+			next[i].Position = *move.Position
+			positioned[move.ID] = true
+		}
+		switch {
+		case move.ParentID != nil && move.RemoveParent:
+			return nil, errConflictingParent
+		case move.ParentID != nil:
+			next[i].ParentID = move.ParentID
+		case move.RemoveParent:
+			next[i].ParentID = nil
+		}
+	}
+
+	// Checked once everything has moved, so a batch can't sneak a bad parent past us
+	for i := range next {
+		if !requested[next[i].ID] || next[i].ParentID == nil {
+			continue
+		}
+		if next[i].IsCategory() {
+			return nil, errCategoryHasParent
+		}
+		parent, ok := indexByID[*next[i].ParentID]
+		if !ok || !next[parent].IsCategory() {
+			return nil, errInvalidParent
+		}
+	}
+
+	// Work with indexes so next and channels still line up afterwards.
+	// Slotting in from the lowest position up puts each one exactly where it asked to be.
+	var order, placed []int
+	for i := range next {
+		if positioned[next[i].ID] {
+			placed = append(placed, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(placed, func(a, b int) bool {
+		return next[placed[a]].Position < next[placed[b]].Position
+	})
+	for _, i := range placed {
+		at := min(next[i].Position, len(order))
+		order = append(order[:at], append([]int{i}, order[at:]...)...)
+	}
+
+	var changed []channelMove
+	for position, i := range order {
+		next[i].Position = position
+		if next[i].Position == channels[i].Position && sameParent(next[i].ParentID, channels[i].ParentID) {
+			continue
+		}
+
+		if err := tx.Model(&models.Channel{}).Where("id = ?", next[i].ID).
+			Updates(map[string]interface{}{"position": next[i].Position, "parent_id": next[i].ParentID}).Error; err != nil {
+			return nil, err
+		}
+		changed = append(changed, channelMove{Before: channels[i], After: next[i], Requested: requested[next[i].ID]})
+	}
+	return changed, nil
+}
+
+func sameParent(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Audits the moves that were asked for and tells everyone about every channel that moved
+func publishChannelMoves(c *gin.Context, serverID uint64, moves []channelMove) {
+	for _, move := range moves {
+		if move.Requested {
+			writeAuditLog(c, serverID, models.AuditChannelUpdate, "channel", idString(move.After.ID), diffUpdates(
+				map[string]interface{}{"position": move.Before.Position, "parent_id": optionalIDString(move.Before.ParentID)},
+				map[string]interface{}{"position": move.After.Position, "parent_id": optionalIDString(move.After.ParentID)},
+			))
+		}
+		broadcastToChannel(serverID, move.After.ID, "CHANNEL_UPDATE", move.After)
+	}
+}
+
+type ReorderChannelsPayload struct {
+	Channels []ChannelPositionPayload `json:"channels" binding:"required,min=1,max=500,dive"`
+}
+
+// ReorderChannels moves and re-parents any number of channels at once. It all lands or none of it does.
+func ReorderChannels(c *gin.Context) {
+	serverID, err := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var payload ReorderChannelsPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var moves []channelMove
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		moves, err = layoutChannels(tx, serverID, payload.Channels)
+		return err
+	})
+	if err != nil {
+		channelLayoutErrorResponse(c, err)
+		return
+	}
+
+	publishChannelMoves(c, serverID, moves)
+
+	c.JSON(http.StatusNoContent, nil)
 }
 
 type CreateChannelPayload struct {
-	Name string             `json:"name" binding:"required,min=1,max=100"`
-	Type models.ChannelType `json:"type" binding:"omitempty,oneof=TEXT VOICE"`
+	Name     string             `json:"name" binding:"required,min=1,max=100"`
+	Type     models.ChannelType `json:"type" binding:"omitempty,oneof=TEXT VOICE CATEGORY ANNOUNCEMENT FORUM"`
+	ParentID *uint64            `json:"parent_id,string"`
+	Topic    string             `json:"topic" binding:"max=1024"`
+	NSFW     bool               `json:"nsfw"`
 }
 
 func CreateChannel(c *gin.Context) {
@@ -64,17 +276,35 @@ func CreateChannel(c *gin.Context) {
 		return
 	}
 
+	// Only categories can hold channels, and they can't hold each other
+	if payload.ParentID != nil {
+		if channelType == models.ChannelTypeCategory {
+			channelLayoutErrorResponse(c, errCategoryHasParent)
+			return
+		}
+		var parent models.Channel
+		if err := database.DB.Where("id = ? AND server_id = ? AND type = ?", *payload.ParentID, serverID, models.ChannelTypeCategory).
+			First(&parent).Error; err != nil {
+			channelLayoutErrorResponse(c, errInvalidParent)
+			return
+		}
+	}
+
 	// Find the current highest position in the server
 	// so we can put this new channel at the bottom of the list automatically!
+	// Threads hang off their parent and aren't part of the sidebar order
 	var maxPosition int
-	database.DB.Model(&models.Channel{}).Where("server_id = ?", serverID).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
+	database.DB.Model(&models.Channel{}).Where("server_id = ? AND type <> ?", serverID, models.ChannelTypeThread).Select("COALESCE(MAX(position), 0)").Scan(&maxPosition)
 
 	channel := models.Channel{
 		ID:       utils.GenerateID(), // Snowflake generator
-		ServerID: serverID,
+		ServerID: &serverID,
 		Name:     payload.Name,
 		Type:     channelType,
 		Position: maxPosition + 1, // Place it at the end
+		ParentID: payload.ParentID,
+		Topic:    payload.Topic,
+		NSFW:     payload.NSFW,
 	}
 
 	if err := database.DB.Create(&channel).Error; err != nil {
@@ -82,12 +312,36 @@ func CreateChannel(c *gin.Context) {
 		return
 	}
 
+	changes := models.AuditChanges{
+		{Key: "name", New: channel.Name},
+		{Key: "type", New: channel.Type},
+		{Key: "position", New: channel.Position},
+	}
+	if channel.ParentID != nil {
+		changes = append(changes, models.AuditChange{Key: "parent_id", New: optionalIDString(channel.ParentID)})
+	}
+	if channel.Topic != "" {
+		changes = append(changes, models.AuditChange{Key: "topic", New: channel.Topic})
+	}
+	if channel.NSFW {
+		changes = append(changes, models.AuditChange{Key: "nsfw", New: channel.NSFW})
+	}
+	writeAuditLog(c, serverID, models.AuditChannelCreate, "channel", idString(channel.ID), changes)
+
+	broadcastToChannel(serverID, channel.ID, "CHANNEL_CREATE", channel)
+
 	c.JSON(http.StatusCreated, channel)
 }
 
 type UpdateChannelPayload struct {
-	Name     *string `json:"name" binding:"omitempty,min=1,max=100"`
-	Position *int    `json:"position" binding:"omitempty,min=0"`
+	Name         *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Position     *int    `json:"position" binding:"omitempty,min=0"`
+	ParentID     *uint64 `json:"parent_id,string"`
+	RemoveParent bool    `json:"remove_parent"` // Takes it out of its category
+	Topic        *string `json:"topic" binding:"omitempty,max=1024"`
+	NSFW         *bool   `json:"nsfw"`
+
+	SlowModeSeconds *int `json:"slow_mode_seconds" binding:"omitempty,min=0,max=21600"` // Up to 6 hours
 }
 
 func UpdateChannel(c *gin.Context) {
@@ -106,8 +360,10 @@ func UpdateChannel(c *gin.Context) {
 	}
 
 	var channel models.Channel
-	// Ensure the channel actually belongs to this specific server before modifying it
-	if err := database.DB.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+	// Ensure the channel actually belongs to this specific server before modifying it.
+	// Threads have their own endpoint, see UpdateThread.
+	if err := database.DB.Where("id = ? AND server_id = ? AND type <> ?", channelID, serverID, models.ChannelTypeThread).
+		First(&channel).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
 		return
 	}
@@ -127,18 +383,69 @@ func UpdateChannel(c *gin.Context) {
 	if payload.Name != nil {
 		updates["name"] = *payload.Name
 	}
-	if payload.Position != nil {
-		updates["position"] = *payload.Position
+	if payload.Topic != nil {
+		updates["topic"] = *payload.Topic
+	}
+	if payload.NSFW != nil {
+		updates["nsfw"] = *payload.NSFW
+	}
+	if payload.SlowModeSeconds != nil {
+		updates["slow_mode_seconds"] = *payload.SlowModeSeconds
 	}
 
-	// Only hit the database if there's actually something to update
-	if len(updates) > 0 {
-		if err := database.DB.Model(&channel).Updates(updates).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update channel"})
-			return
+	// Snapshot the current values so the audit log can show what changed
+	changes := diffUpdates(map[string]interface{}{
+		"name":              channel.Name,
+		"topic":             channel.Topic,
+		"nsfw":              channel.NSFW,
+		"slow_mode_seconds": channel.SlowModeSeconds,
+	}, updates)
+
+	// Moving goes through the same layout as a bulk reorder so positions stay unique
+	var moves []channelMove
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only hit the database if there's actually something to update
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Channel{}).Where("id = ?", channel.ID).Updates(updates).Error; err != nil {
+				return err
+			}
 		}
+		if payload.Position != nil || payload.ParentID != nil || payload.RemoveParent {
+			moves, err = layoutChannels(tx, serverID, []ChannelPositionPayload{{
+				ID:           channel.ID,
+				Position:     payload.Position,
+				ParentID:     payload.ParentID,
+				RemoveParent: payload.RemoveParent,
+			}})
+			if err != nil {
+				return err
+			}
+		}
+		return withForumTags(tx).First(&channel, channel.ID).Error
+	})
+	if err != nil {
+		channelLayoutErrorResponse(c, err)
+		return
 	}
 
+	// The move is folded into this channel's own entry and broadcast below
+	for _, move := range moves {
+		if move.Requested {
+			changes = append(changes, diffUpdates(
+				map[string]interface{}{"position": move.Before.Position, "parent_id": optionalIDString(move.Before.ParentID)},
+				map[string]interface{}{"position": move.After.Position, "parent_id": optionalIDString(move.After.ParentID)},
+			)...)
+		} else {
+			broadcastToChannel(serverID, move.After.ID, "CHANNEL_UPDATE", move.After)
+		}
+	}
+
+	if len(changes) > 0 {
+		writeAuditLog(c, serverID, models.AuditChannelUpdate, "channel", idString(channel.ID), changes)
+	}
+
+	broadcastToChannel(serverID, channel.ID, "CHANNEL_UPDATE", channel)
+
 	c.JSON(http.StatusOK, channel)
 }
 
@@ -157,11 +464,284 @@ func DeleteChannel(c *gin.Context) {
 		return
 	}
 
-	// Soft-delete channel, and all messages inside this channel
-	if err := database.DB.Delete(&channel).Error; err != nil {
+	// Worked out now, a deleted channel has no overwrites left to check
+	viewers, viewersErr := permissions.ChannelViewers(serverID, channel.ID)
+
+	// Soft-delete channel. Its messages are left alone so a restore brings the history back.
+	// Threads go with it, sharing the timestamp so a restore can find them again.
+	// Channels in a deleted category stay, they just aren't in a category anymore.
+	var orphans []models.Channel
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Channel{}).
+			Where("id = ? OR (parent_id = ? AND type = ?)", channel.ID, channel.ID, models.ChannelTypeThread).
+			Update("deleted_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if !channel.IsCategory() {
+			return nil
+		}
+		if err := tx.Where("parent_id = ?", channel.ID).Find(&orphans).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Channel{}).Where("parent_id = ?", channel.ID).Update("parent_id", nil).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete channel"})
 		return
 	}
 
+	writeAuditLog(c, serverID, models.AuditChannelDelete, "channel", idString(channel.ID), models.AuditChanges{
+		{Key: "name", Old: channel.Name},
+		{Key: "type", Old: channel.Type},
+	})
+
+	if viewersErr != nil {
+		log.Printf("Failed to resolve viewers for deleted channel %d: %v", channel.ID, viewersErr)
+	} else {
+		websockets.Manager.Broadcast <- websockets.WsMessage{
+			TargetServerID:  serverID,
+			TargetChannelID: channel.ID,
+			Event:           "CHANNEL_DELETE",
+			Data:            channel,
+			VisibleTo:       viewers,
+		}
+	}
+	for _, orphan := range orphans {
+		orphan.ParentID = nil
+		broadcastToChannel(serverID, orphan.ID, "CHANNEL_UPDATE", orphan)
+	}
+
 	c.JSON(http.StatusNoContent, nil) // 204 No Content
 }
+
+func RestoreChannel(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	// Only channels still inside the retention window can come back
+	var channel models.Channel
+	if err := database.DB.Unscoped().
+		Where("id = ? AND server_id = ? AND deleted_at > ?", channelID, serverID, time.Now().Add(-database.Retention)).
+		First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No restorable channel found"})
+		return
+	}
+
+	updates := map[string]interface{}{"deleted_at": nil}
+	if channel.IsThread() {
+		// A thread can't come back without the channel it lives in
+		var parent models.Channel
+		if err := database.DB.First(&parent, *channel.ParentID).Error; err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Restore the parent channel first"})
+			return
+		}
+	} else {
+		// Someone may have reused the name while it was gone
+		var existingChannel models.Channel
+		err = database.DB.Where("server_id = ? AND name = ? AND type = ?", serverID, channel.Name, channel.Type).First(&existingChannel).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "A " + string(channel.Type) + " channel with that name already exists"})
+			return
+		}
+
+		// Its category may have been deleted in the meantime, it comes back without one
+		if channel.ParentID != nil {
+			var parent models.Channel
+			if err := database.DB.Where("id = ? AND type = ?", *channel.ParentID, models.ChannelTypeCategory).First(&parent).Error; err != nil {
+				updates["parent_id"] = nil
+				channel.ParentID = nil
+			}
+		}
+	}
+
+	// Threads deleted along with the channel come back with it
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Channel{}).Where("id = ?", channel.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&models.Channel{}).
+			Where("parent_id = ? AND type = ? AND deleted_at = ?", channel.ID, models.ChannelTypeThread, channel.DeletedAt).
+			Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore channel"})
+		return
+	}
+	channel.DeletedAt = gorm.DeletedAt{}
+
+	writeAuditLog(c, serverID, models.AuditChannelRestore, "channel", idString(channel.ID), models.AuditChanges{
+		{Key: "name", New: channel.Name},
+	})
+
+	broadcastToChannel(serverID, channel.ID, "CHANNEL_CREATE", channel)
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// Overwrites follow the same hierarchy as roles. Editing one for a role or member at or above
+// you would be a way around it. Targets that no longer exist are fair game so they can be cleaned up.
+func canManageOverwriteTarget(member *permissions.Member, serverID uint64, overwriteType models.OverwriteType, targetID uint64) bool {
+	switch overwriteType {
+	case models.OverwriteTypeRole:
+		var role models.Role
+		if err := database.DB.Where("id = ? AND server_id = ?", targetID, serverID).First(&role).Error; err != nil {
+			return true
+		}
+		return role.IsDefault || member.CanManageRole(role)
+	case models.OverwriteTypeMember:
+		if targetID == member.UserID {
+			return true
+		}
+		target, err := permissions.Resolve(serverID, targetID)
+		if err != nil {
+			return true
+		}
+		return member.Outranks(target)
+	}
+	return true
+}
+
+type ChannelOverwritePayload struct {
+	Type  models.OverwriteType `json:"type" binding:"required,oneof=ROLE MEMBER"`
+	Allow models.Permission    `json:"allow,string"`
+	Deny  models.Permission    `json:"deny,string"`
+}
+
+func UpsertChannelOverwrite(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+	targetID, err := strconv.ParseUint(c.Param("targetID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return
+	}
+
+	var payload ChannelOverwritePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Administrator is server-wide only, in a channel it would wipe out timeouts and every deny
+	if (payload.Allow | payload.Deny).Has(models.PermissionAdministrator) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Administrator cannot be set on a channel overwrite"})
+		return
+	}
+
+	var channel models.Channel
+	if err := database.DB.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	// The target has to be a role or an active member of this server
+	switch payload.Type {
+	case models.OverwriteTypeRole:
+		var role models.Role
+		if err := database.DB.Where("id = ? AND server_id = ?", targetID, serverID).First(&role).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+	case models.OverwriteTypeMember:
+		var target models.ServerMember
+		if err := database.DB.Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, targetID).First(&target).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+	}
+
+	member := currentMember(c)
+	if !canManageOverwriteTarget(member, serverID, payload.Type, targetID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change permissions for a role or member at or above your highest role"})
+		return
+	}
+
+	// Nobody can allow or deny permissions they don't have themselves
+	if !member.Has(payload.Allow | payload.Deny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change permissions you do not have"})
+		return
+	}
+
+	// Load the existing overwrite, if any, so the audit log can show what changed
+	var previous models.PermissionOverwrite
+	database.DB.Where("channel_id = ? AND target_id = ?", channelID, targetID).Limit(1).Find(&previous)
+
+	overwrite := models.PermissionOverwrite{
+		ChannelID: channelID,
+		TargetID:  targetID,
+		Type:      payload.Type,
+		Allow:     payload.Allow,
+		Deny:      payload.Deny,
+	}
+
+	// Save inserts or replaces the row keyed by channel and target
+	if err := database.DB.Save(&overwrite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save channel permissions"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelOverwriteUpdate, "channel", idString(channelID), diffUpdates(
+		map[string]interface{}{
+			"target_id": idString(previous.TargetID),
+			"type":      previous.Type,
+			"allow":     previous.Allow,
+			"deny":      previous.Deny,
+		},
+		map[string]interface{}{
+			"target_id": idString(targetID),
+			"type":      overwrite.Type,
+			"allow":     overwrite.Allow,
+			"deny":      overwrite.Deny,
+		},
+	))
+
+	c.JSON(http.StatusOK, overwrite)
+}
+
+func DeleteChannelOverwrite(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+	targetID, err := strconv.ParseUint(c.Param("targetID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return
+	}
+
+	var channel models.Channel
+	if err := database.DB.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return
+	}
+
+	var existing models.PermissionOverwrite
+	if err := database.DB.Where("channel_id = ? AND target_id = ?", channelID, targetID).First(&existing).Error; err == nil {
+		if !canManageOverwriteTarget(currentMember(c), serverID, existing.Type, targetID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot change permissions for a role or member at or above your highest role"})
+			return
+		}
+	}
+
+	if err := database.DB.Where("channel_id = ? AND target_id = ?", channelID, targetID).
+		Delete(&models.PermissionOverwrite{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete channel permissions"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelOverwriteDelete, "channel", idString(channelID), models.AuditChanges{
+		{Key: "target_id", Old: idString(targetID)},
+	})
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+// Sends an event to every connection of every participant in a DM
+func broadcastToDM(channelID uint64, event string, data interface{}) {
+	recipients, err := permissions.DMRecipients(channelID)
+	if err != nil {
+		log.Printf("Failed to resolve recipients for DM %d: %v", channelID, err)
+		return
+	}
+	broadcastToUsers(recipients, channelID, event, data)
+}
+
+// Sends an event straight to a set of users, outside of any server room
+func broadcastToUsers(userIDs []uint64, channelID uint64, event string, data interface{}) {
+	if len(userIDs) == 0 {
+		return
+	}
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetChannelID: channelID,
+		Event:           event,
+		Data:            data,
+		TargetUserIDs:   userIDs,
+	}
+}
+
+// Helper to load a DM channel along with its participants
+func loadDMChannel(channelID uint64) (models.Channel, error) {
+	var channel models.Channel
+	err := database.DB.Preload("Recipients", func(db *gorm.DB) *gorm.DB {
+		return db.Order("joined_at asc")
+	}).Preload("Recipients.User").
+		Where("id = ? AND type IN ?", channelID, []models.ChannelType{models.ChannelTypeDM, models.ChannelTypeGroupDM}).
+		First(&channel).Error
+	return channel, err
+}
+
+func ListDMChannels(c *gin.Context) {
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// Most recently active conversations first, falling back to when the channel was opened
+	var channels []models.Channel
+	if err := database.DB.Preload("Recipients", func(db *gorm.DB) *gorm.DB {
+		return db.Order("joined_at asc")
+	}).Preload("Recipients.User").
+		Where("id IN (?)", database.DB.Model(&models.DMRecipient{}).Select("channel_id").Where("user_id = ?", userID)).
+		Order("COALESCE((SELECT MAX(messages.id) FROM messages WHERE messages.channel_id = channels.id AND messages.deleted_at IS NULL), channels.id) desc").
+		Find(&channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch direct messages"})
+		return
+	}
+
+	if err := attachReadStates(channels, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch read states"})
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+type CreateDMPayload struct {
+	RecipientIDs []string `json:"recipient_ids" binding:"required,min=1"`
+	Name         string   `json:"name" binding:"omitempty,max=100"` // Group DMs only
+}
+
+func CreateDMChannel(c *gin.Context) {
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var payload CreateDMPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Dedupe and drop the caller, they're always included
+	seen := map[uint64]bool{userID: true}
+	recipientIDs := []uint64{userID}
+	for _, raw := range payload.RecipientIDs {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			recipientIDs = append(recipientIDs, id)
+		}
+	}
+
+	if len(recipientIDs) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot start a conversation with yourself"})
+		return
+	}
+	if len(recipientIDs) > models.MaxGroupDMRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group DMs are limited to " + strconv.Itoa(models.MaxGroupDMRecipients) + " participants"})
+		return
+	}
+
+	var found int64
+	database.DB.Model(&models.User{}).Where("id IN ?", recipientIDs).Count(&found)
+	if int(found) != len(recipientIDs) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	channelType := models.ChannelTypeGroupDM
+	if len(recipientIDs) == 2 {
+		channelType = models.ChannelTypeDM
+
+		// There's only ever one DM between two people, hand back the existing one
+		var existing models.Channel
+		err := database.DB.Where("type = ? AND id IN (?) AND id IN (?)", models.ChannelTypeDM,
+			database.DB.Model(&models.DMRecipient{}).Select("channel_id").Where("user_id = ?", recipientIDs[0]),
+			database.DB.Model(&models.DMRecipient{}).Select("channel_id").Where("user_id = ?", recipientIDs[1])).
+			First(&existing).Error
+		if err == nil {
+			channel, _ := loadDMChannel(existing.ID)
+			c.JSON(http.StatusOK, channel)
+			return
+		}
+	}
+
+	channel := models.Channel{
+		ID:   utils.GenerateID(),
+		Type: channelType,
+	}
+	if channelType == models.ChannelTypeGroupDM {
+		channel.Name = payload.Name
+		channel.OwnerID = &userID
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&channel).Error; err != nil {
+			return err
+		}
+		for _, id := range recipientIDs {
+			if err := tx.Create(&models.DMRecipient{ChannelID: channel.ID, UserID: id}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create channel"})
+		return
+	}
+
+	channel, _ = loadDMChannel(channel.ID)
+
+	broadcastToUsers(recipientIDs, channel.ID, "CHANNEL_CREATE", channel)
+
+	c.JSON(http.StatusCreated, channel)
+}
+
+// Shared lookups for adding and removing group DM participants
+func resolveGroupDMTarget(c *gin.Context) (models.Channel, uint64, bool) {
+	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
+
+	channel, err := loadDMChannel(channelID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return channel, 0, false
+	}
+
+	if channel.Type != models.ChannelTypeGroupDM {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Participants can only be changed in group DMs"})
+		return channel, 0, false
+	}
+
+	targetUserID, err := parseTargetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return channel, 0, false
+	}
+
+	return channel, targetUserID, true
+}
+
+func AddDMRecipient(c *gin.Context) {
+	channel, targetUserID, ok := resolveGroupDMTarget(c)
+	if !ok {
+		return
+	}
+
+	for _, r := range channel.Recipients {
+		if r.UserID == targetUserID {
+			c.JSON(http.StatusConflict, gin.H{"error": "User is already in this group"})
+			return
+		}
+	}
+
+	if len(channel.Recipients) >= models.MaxGroupDMRecipients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Group DMs are limited to " + strconv.Itoa(models.MaxGroupDMRecipients) + " participants"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Select("id").First(&user, targetUserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	recipient := models.DMRecipient{ChannelID: channel.ID, UserID: targetUserID}
+	if err := database.DB.Create(&recipient).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add participant"})
+		return
+	}
+
+	database.DB.Preload("User").Where("channel_id = ? AND user_id = ?", channel.ID, targetUserID).First(&recipient)
+
+	// Everyone already there hears about the new face, the new face gets the whole channel
+	existing := make([]uint64, 0, len(channel.Recipients))
+	for _, r := range channel.Recipients {
+		existing = append(existing, r.UserID)
+	}
+	broadcastToUsers(existing, channel.ID, "CHANNEL_RECIPIENT_ADD", gin.H{
+		"channel_id": idString(channel.ID),
+		"recipient":  recipient,
+	})
+
+	channel, _ = loadDMChannel(channel.ID)
+	broadcastToUsers([]uint64{targetUserID}, channel.ID, "CHANNEL_CREATE", channel)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func RemoveDMRecipient(c *gin.Context) {
+	channel, targetUserID, ok := resolveGroupDMTarget(c)
+	if !ok {
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// Anyone can leave, only the owner can remove someone else
+	isOwner := channel.OwnerID != nil && *channel.OwnerID == userID
+	if targetUserID != userID && !isOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the group owner can remove participants"})
+		return
+	}
+
+	var remaining []uint64
+	found := false
+	for _, r := range channel.Recipients {
+		if r.UserID == targetUserID {
+			found = true
+			continue
+		}
+		remaining = append(remaining, r.UserID)
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not in this group"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("channel_id = ? AND user_id = ?", channel.ID, targetUserID).
+			Delete(&models.DMRecipient{}).Error; err != nil {
+			return err
+		}
+
+		// The last one out closes the channel, the purger cleans up the history later
+		// Bare models here, the preloaded recipients would otherwise be saved back
+		if len(remaining) == 0 {
+			return tx.Delete(&models.Channel{}, channel.ID).Error
+		}
+
+		// Ownership passes to whoever has been around the longest
+		if channel.OwnerID != nil && *channel.OwnerID == targetUserID {
+			channel.OwnerID = &remaining[0]
+			return tx.Model(&models.Channel{}).Where("id = ?", channel.ID).Update("owner_id", remaining[0]).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove participant"})
+		return
+	}
+
+	broadcastToUsers(remaining, channel.ID, "CHANNEL_RECIPIENT_REMOVE", gin.H{
+		"channel_id": idString(channel.ID),
+		"user_id":    idString(targetUserID),
+		"owner_id":   channel.OwnerID,
+	})
+	broadcastToUsers([]uint64{targetUserID}, channel.ID, "CHANNEL_DELETE", gin.H{"id": idString(channel.ID)})
+
+	c.JSON(http.StatusNoContent, nil)
+}
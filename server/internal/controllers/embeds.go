@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"log"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/unfurl"
+)
+
+// Fetches previews for the links in a message in the background. They show up
+// afterwards as a MESSAGE_UPDATE, as long as the message hasn't changed since.
+func queueEmbeds(serverID uint64, message models.Message) {
+	if unfurl.Default == nil {
+		return
+	}
+	urls := unfurl.FindURLs(message.Content)
+	if len(urls) == 0 {
+		return
+	}
+
+	content := message.Content
+	queued := unfurl.Default.Submit(unfurl.Job{
+		URLs: urls,
+		Done: func(embeds []models.Embed) {
+			if len(embeds) == 0 {
+				return
+			}
+
+			// An edit or delete while we were fetching makes these stale, the edit queues its own
+			result := database.DB.Model(&models.Message{}).
+				Where("id = ? AND content = ?", message.ID, content).
+				Update("embeds", models.Embeds(embeds))
+			if result.Error != nil {
+				log.Printf("Failed to save embeds for message %d: %v", message.ID, result.Error)
+				return
+			}
+			if result.RowsAffected == 0 {
+				return
+			}
+
+			var updated models.Message
+			if err := withMessageRelations(database.DB).First(&updated, message.ID).Error; err != nil {
+				return
+			}
+			broadcastToChannel(serverID, updated.ChannelID, "MESSAGE_UPDATE", updated)
+		},
+	})
+	if !queued {
+		log.Printf("Link preview queue is full, skipping embeds for message %d", message.ID)
+	}
+}
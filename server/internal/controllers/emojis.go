@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+const maxEmojisPerServer = 50
+
+var errEmojiLimit = errors.New("server emoji limit reached")
+
+func ListEmojis(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var emojis []models.Emoji
+	if err := database.DB.Where("server_id = ?", serverID).Order("name asc").Find(&emojis).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emojis"})
+		return
+	}
+
+	c.JSON(http.StatusOK, emojis)
+}
+
+type CreateEmojiPayload struct {
+	Name     string `json:"name" binding:"required,min=2,max=32,alphanum"`
+	ImageURL string `json:"image_url" binding:"required,url"`
+}
+
+func CreateEmoji(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var payload CreateEmojiPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+
+	emoji := models.Emoji{
+		ID:        utils.GenerateID(),
+		ServerID:  serverID,
+		Name:      payload.Name,
+		ImageURL:  payload.ImageURL,
+		CreatorID: userIDObj.(uint64),
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the server makes racing uploads take turns, so the count can't go stale
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Server{}, serverID).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Emoji{}).Where("server_id = ?", serverID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= maxEmojisPerServer {
+			return errEmojiLimit
+		}
+
+		// Names are how people type them, so keep them unique per server. The index backs this up.
+		var existing int64
+		if err := tx.Model(&models.Emoji{}).Where("server_id = ? AND name = ?", serverID, payload.Name).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		return tx.Create(&emoji).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errEmojiLimit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Servers can have at most " + strconv.Itoa(maxEmojisPerServer) + " emojis"})
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			c.JSON(http.StatusConflict, gin.H{"error": "An emoji with that name already exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create emoji"})
+		}
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditEmojiCreate, "emoji", idString(emoji.ID), models.AuditChanges{
+		{Key: "name", New: emoji.Name},
+	})
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "EMOJI_CREATE",
+		Data:           emoji,
+	}
+
+	c.JSON(http.StatusCreated, emoji)
+}
+
+func DeleteEmoji(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var emoji models.Emoji
+	if err := database.DB.Where("id = ? AND server_id = ?", c.Param("emojiID"), serverID).First(&emoji).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Emoji not found"})
+		return
+	}
+
+	// Reactions using it would have nothing left to render
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("emoji = ?", idString(emoji.ID)).Delete(&models.Reaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&emoji).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete emoji"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditEmojiDelete, "emoji", idString(emoji.ID), models.AuditChanges{
+		{Key: "name", Old: emoji.Name},
+	})
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "EMOJI_DELETE",
+		Data:           gin.H{"id": idString(emoji.ID)},
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
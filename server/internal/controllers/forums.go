@@ -0,0 +1,495 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/ratelimit"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+const (
+	defaultForumPostLimit = 25
+	maxForumPostLimit     = 100
+)
+
+var (
+	errTooManyTags = errors.New("too many tags")
+	errUnknownTag  = errors.New("tag not in this forum")
+	errTagLimit    = errors.New("forum tag limit reached")
+)
+
+// Loads a forum's tags along with it, oldest first
+func withForumTags(db *gorm.DB) *gorm.DB {
+	return db.Preload("AvailableTags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	})
+}
+
+// Loads the tags on a forum post, in the order the forum added them
+func withPostTags(db *gorm.DB) *gorm.DB {
+	return db.Preload("AppliedTags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	})
+}
+
+// Helper to grab the channel loaded by RequireChannelPermission, if it's a forum
+func currentForum(c *gin.Context) (models.Channel, bool) {
+	channelObj, _ := c.Get("channel")
+	channel := channelObj.(models.Channel)
+	return channel, channel.IsForum()
+}
+
+// Checks the tags picked for a post against the ones its forum offers. Repeats are dropped.
+func resolveForumTags(forumID uint64, rawIDs []string) ([]models.ForumTag, error) {
+	seen := make(map[uint64]bool)
+	var ids []uint64
+	for _, raw := range rawIDs {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return nil, errUnknownTag
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) > models.MaxTagsPerPost {
+		return nil, errTooManyTags
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var tags []models.ForumTag
+	if err := database.DB.Where("channel_id = ? AND id IN ?", forumID, ids).Order("id asc").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	if len(tags) != len(ids) {
+		return nil, errUnknownTag
+	}
+	return tags, nil
+}
+
+// Turns an error from resolveForumTags into a response
+func forumTagErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTooManyTags):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A post can have at most " + strconv.Itoa(models.MaxTagsPerPost) + " tags"})
+	case errors.Is(err, errUnknownTag):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tags must be ones this forum offers"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tags"})
+	}
+}
+
+// Swaps out the tags on a post. Rows are written by hand so the tags themselves are never touched.
+func replacePostTags(tx *gorm.DB, threadID uint64, tags []models.ForumTag) error {
+	if err := tx.Where("thread_id = ?", threadID).Delete(&models.ForumPostTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]models.ForumPostTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, models.ForumPostTag{ThreadID: threadID, TagID: tag.ID})
+	}
+	return tx.Create(&rows).Error
+}
+
+// Tag changes show up as the forum itself changing
+func broadcastForumUpdate(serverID uint64, forumID uint64) {
+	var forum models.Channel
+	if err := withForumTags(database.DB).First(&forum, forumID).Error; err != nil {
+		return
+	}
+	broadcastToChannel(serverID, forum.ID, "CHANNEL_UPDATE", forum)
+}
+
+type CreateForumTagPayload struct {
+	Name  string `json:"name" binding:"required,min=1,max=50"`
+	Emoji string `json:"emoji" binding:"max=64"`
+}
+
+func CreateForumTag(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	forum, ok := currentForum(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tags can only be added to forum channels"})
+		return
+	}
+
+	var payload CreateForumTagPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := models.ForumTag{
+		ID:        utils.GenerateID(),
+		ChannelID: forum.ID,
+		Name:      payload.Name,
+		Emoji:     payload.Emoji,
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the forum row makes racing creates take turns, so the count can't go stale
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Channel{}, forum.ID).Error; err != nil {
+			return err
+		}
+
+		var tags []models.ForumTag
+		if err := tx.Where("channel_id = ?", forum.ID).Find(&tags).Error; err != nil {
+			return err
+		}
+		if len(tags) >= models.MaxTagsPerForum {
+			return errTagLimit
+		}
+		for _, existing := range tags {
+			if strings.EqualFold(existing.Name, payload.Name) {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+		return tx.Create(&tag).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errTagLimit):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A forum can have at most " + strconv.Itoa(models.MaxTagsPerForum) + " tags"})
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			c.JSON(http.StatusConflict, gin.H{"error": "This forum already has a tag with that name"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		}
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelUpdate, "channel", idString(forum.ID), models.AuditChanges{
+		{Key: "tag", New: tag.Name},
+	})
+	broadcastForumUpdate(serverID, forum.ID)
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+type UpdateForumTagPayload struct {
+	Name  *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Emoji *string `json:"emoji" binding:"omitempty,max=64"`
+}
+
+func UpdateForumTag(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	forum, ok := currentForum(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var payload UpdateForumTagPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tag models.ForumTag
+	if err := database.DB.Where("id = ? AND channel_id = ?", c.Param("tagID"), forum.ID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Name != nil {
+		var existing int64
+		database.DB.Model(&models.ForumTag{}).
+			Where("channel_id = ? AND id <> ? AND LOWER(name) = LOWER(?)", forum.ID, tag.ID, *payload.Name).
+			Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "This forum already has a tag with that name"})
+			return
+		}
+		updates["name"] = *payload.Name
+	}
+	if payload.Emoji != nil {
+		updates["emoji"] = *payload.Emoji
+	}
+
+	changes := diffUpdates(map[string]interface{}{"name": tag.Name, "emoji": tag.Emoji}, updates)
+	if len(updates) > 0 {
+		if err := database.DB.Model(&tag).Updates(updates).Error; err != nil {
+			// Another rename got to the name first
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				c.JSON(http.StatusConflict, gin.H{"error": "This forum already has a tag with that name"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+			return
+		}
+	}
+
+	if len(changes) > 0 {
+		for i := range changes {
+			changes[i].Key = "tag_" + changes[i].Key
+		}
+		writeAuditLog(c, serverID, models.AuditChannelUpdate, "channel", idString(forum.ID), changes)
+		broadcastForumUpdate(serverID, forum.ID)
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteForumTag removes a tag from the forum and from every post wearing it
+func DeleteForumTag(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	forum, ok := currentForum(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var tag models.ForumTag
+	if err := database.DB.Where("id = ? AND channel_id = ?", c.Param("tagID"), forum.ID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.ForumPostTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditChannelUpdate, "channel", idString(forum.ID), models.AuditChanges{
+		{Key: "tag", Old: tag.Name},
+	})
+	broadcastForumUpdate(serverID, forum.ID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// ForumPost is a forum thread along with the message that opened it
+type ForumPost struct {
+	models.Channel
+	FirstMessage *models.Message `json:"first_message"`
+}
+
+// ForumPostPage is one page of a forum's posts
+type ForumPostPage struct {
+	Posts   []ForumPost `json:"posts"`
+	HasMore bool        `json:"has_more"`
+}
+
+type CreateForumPostPayload struct {
+	Title               string   `json:"title" binding:"required,min=1,max=100"`
+	Content             string   `json:"content" binding:"required,min=1,max=2000"`
+	AppliedTags         []string `json:"applied_tags"`
+	AutoArchiveDuration int      `json:"auto_archive_duration"` // Minutes, defaults to a day
+}
+
+// CreateForumPost opens a new thread in a forum, with its first message already in it
+func CreateForumPost(c *gin.Context) {
+	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
+	forum, ok := currentForum(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Posts can only be created in forum channels"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// A post is a message too, it comes out of the same budget
+	if ok, retryAfter := ratelimit.Messages.Allow(strconv.FormatUint(userID, 10)); !ok {
+		rateLimitedResponse(c, retryAfter, "You are sending messages too quickly")
+		return
+	}
+
+	var payload CreateForumPostPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if payload.AutoArchiveDuration == 0 {
+		payload.AutoArchiveDuration = models.DefaultThreadAutoArchiveDuration
+	}
+	if !validAutoArchiveDuration(payload.AutoArchiveDuration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auto_archive_duration must be one of 60, 1440, 4320 or 10080"})
+		return
+	}
+
+	tags, err := resolveForumTags(forum.ID, payload.AppliedTags)
+	if err != nil {
+		forumTagErrorResponse(c, err)
+		return
+	}
+
+	// Slow mode on a forum paces new posts, replies follow the post's own setting
+	perms := currentChannelPermissions(c)
+	slowModeKey := ""
+	if forum.SlowModeSeconds > 0 && !perms.Has(models.PermissionBypassSlowMode) {
+		slowModeKey = strconv.FormatUint(forum.ID, 10) + ":" + strconv.FormatUint(userID, 10)
+		if ok, retryAfter := ratelimit.SlowMode.Take(slowModeKey, time.Duration(forum.SlowModeSeconds)*time.Second); !ok {
+			rateLimitedResponse(c, retryAfter, "This channel is in slow mode")
+			return
+		}
+	}
+
+	now := time.Now()
+	thread := models.Channel{
+		ID:                  utils.GenerateID(),
+		ServerID:            &serverID,
+		Name:                payload.Title,
+		Type:                models.ChannelTypeThread,
+		OwnerID:             &userID,
+		ParentID:            &forum.ID,
+		AutoArchiveDuration: payload.AutoArchiveDuration,
+		LastActivityAt:      &now,
+	}
+	message := models.Message{
+		ID:        utils.GenerateID(),
+		ChannelID: thread.ID,
+		AuthorID:  &userID,
+		Content:   payload.Content,
+	}
+	mentions := resolveMentions(serverID, thread.ID, payload.Content, perms)
+	applyMentions(&message, mentions)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&thread).Error; err != nil {
+			return err
+		}
+		if err := replacePostTags(tx, thread.ID, tags); err != nil {
+			return err
+		}
+		return tx.Create(&message).Error
+	})
+	if err != nil {
+		ratelimit.SlowMode.Clear(slowModeKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post"})
+		return
+	}
+	thread.AppliedTags = tags
+
+	// The thread has to exist for clients before its first message arrives
+	broadcastToChannel(serverID, thread.ID, "THREAD_CREATE", thread)
+	publishMessage(serverID, thread, &message, mentions)
+
+	c.JSON(http.StatusCreated, ForumPost{Channel: thread, FirstMessage: &message})
+}
+
+// ListForumPosts pages through a forum. ?sort=activity (default) or created, ?tag= to only show posts
+// with any of the given tags, ?archived=true or false to pick between closed and open posts.
+func ListForumPosts(c *gin.Context) {
+	forum, ok := currentForum(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Posts can only be listed in forum channels"})
+		return
+	}
+
+	query := withPostTags(database.DB).Where("parent_id = ? AND type = ?", forum.ID, models.ChannelTypeThread)
+
+	switch c.DefaultQuery("sort", "activity") {
+	case "activity":
+		query = query.Order("last_activity_at desc").Order("id desc")
+	case "created":
+		query = query.Order("id desc")
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be activity or created"})
+		return
+	}
+
+	if raw := c.Query("archived"); raw != "" {
+		archived, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "archived must be true or false"})
+			return
+		}
+		query = query.Where("archived = ?", archived)
+	}
+
+	if rawTags := c.QueryArray("tag"); len(rawTags) > 0 {
+		tagIDs := make([]uint64, 0, len(rawTags))
+		for _, raw := range rawTags {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tag ID"})
+				return
+			}
+			tagIDs = append(tagIDs, id)
+		}
+		query = query.Where("id IN (?)", database.DB.Model(&models.ForumPostTag{}).Select("thread_id").Where("tag_id IN ?", tagIDs))
+	}
+
+	limit := defaultForumPostLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxForumPostLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxForumPostLimit)})
+			return
+		}
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must be zero or more"})
+		return
+	}
+
+	// One extra row tells us whether there's another page
+	var threads []models.Channel
+	if err := query.Limit(limit + 1).Offset(offset).Find(&threads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	page := ForumPostPage{Posts: []ForumPost{}, HasMore: len(threads) > limit}
+	if page.HasMore {
+		threads = threads[:limit]
+	}
+	if len(threads) == 0 {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := attachReadStates(threads, userIDObj.(uint64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch read states"})
+		return
+	}
+
+	// The opening message of each post, for previews. If it was deleted the earliest one left stands in.
+	threadIDs := make([]uint64, 0, len(threads))
+	for _, thread := range threads {
+		threadIDs = append(threadIDs, thread.ID)
+	}
+	var firstMessages []models.Message
+	if err := withMessageRelations(database.DB).
+		Where("id IN (?)", database.DB.Model(&models.Message{}).Select("MIN(id)").Where("channel_id IN ?", threadIDs).Group("channel_id")).
+		Find(&firstMessages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch posts"})
+		return
+	}
+	byThread := make(map[uint64]*models.Message, len(firstMessages))
+	for i := range firstMessages {
+		byThread[firstMessages[i].ChannelID] = &firstMessages[i]
+	}
+
+	for _, thread := range threads {
+		page.Posts = append(page.Posts, ForumPost{Channel: thread, FirstMessage: byThread[thread.ID]})
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/imaging"
+	"github.com/jonahgcarpenter/hermes/server/internal/storage"
+)
+
+const (
+	maxImageUploadSize = 8 << 20
+	profileImageSize   = 512 // The variant avatar_url and icon_url point at
+	thumbnailSize      = 400
+)
+
+// Avatars and server icons are kept at these sizes, each as both WebP and PNG
+var profileImageSizes = []int{128, 256, 512}
+
+var (
+	imageHashPattern    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	imageVariantPattern = regexp.MustCompile(`^[0-9]+\.(webp|png)$`)
+)
+
+var errImageTooLarge = errors.New("image file too large")
+
+// Turns an error from decodeUploadedImage or storeProfileImage into a response
+func imageErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Images can be at most " + strconv.Itoa(maxImageUploadSize>>20) + " MB"})
+	case errors.Is(err, imaging.ErrTooLarge):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image dimensions are too large"})
+	case errors.Is(err, imaging.ErrUnsupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Images must be PNG, JPEG, GIF or WebP"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process image"})
+	}
+}
+
+// Decodes the image sent in a multipart field, nil if the field wasn't sent
+func decodeUploadedImage(c *gin.Context, field string) (image.Image, error) {
+	if c.ContentType() != "multipart/form-data" {
+		return nil, nil
+	}
+	fh, err := c.FormFile(field)
+	if err != nil {
+		return nil, nil
+	}
+	if fh.Size > maxImageUploadSize {
+		return nil, errImageTooLarge
+	}
+
+	file, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return imaging.Decode(file)
+}
+
+// Crops an image square, renders every profile size and stores the set under a hash of
+// its contents. Returns the URL to save as the avatar or icon, absolute so clients can
+// drop it straight into an img tag wherever they're served from.
+func storeProfileImage(img image.Image) (string, error) {
+	variants := make(map[string][]byte)
+	var canonical []byte
+	for _, size := range profileImageSizes {
+		square := imaging.Square(img, size)
+
+		pngData, err := imaging.EncodePNG(square)
+		if err != nil {
+			return "", err
+		}
+		webpData, err := imaging.EncodeWebP(square)
+		if err != nil {
+			return "", err
+		}
+
+		variants[strconv.Itoa(size)+".png"] = pngData
+		variants[strconv.Itoa(size)+".webp"] = webpData
+		if size == profileImageSize {
+			canonical = pngData
+		}
+	}
+
+	// Same pixels, same hash, so uploading an image twice just rewrites the same files
+	sum := sha256.Sum256(canonical)
+	hash := hex.EncodeToString(sum[:16])
+
+	for name, data := range variants {
+		if err := storage.Store.Put(context.Background(), "images/"+hash+"/"+name, bytes.NewReader(data), int64(len(data)), imageContentType(name)); err != nil {
+			return "", err
+		}
+	}
+
+	return storage.PublicURL + "/api/images/" + hash + "/" + strconv.Itoa(profileImageSize) + ".webp", nil
+}
+
+// Builds the generated avatar users get until they upload their own
+func defaultAvatarURL(userID uint64) string {
+	url, err := storeProfileImage(imaging.Identicon(idString(userID)))
+	if err != nil {
+		log.Printf("Failed to generate default avatar for user %d: %v", userID, err)
+		return ""
+	}
+	return url
+}
+
+// Makes a preview of an image attachment, stored next to the original
+func storeThumbnail(key string, img image.Image) (string, error) {
+	data, err := imaging.EncodeWebP(imaging.Fit(img, thumbnailSize, thumbnailSize))
+	if err != nil {
+		return "", err
+	}
+	thumbnailKey := key + ".thumb.webp"
+	if err := storage.Store.Put(context.Background(), thumbnailKey, bytes.NewReader(data), int64(len(data)), "image/webp"); err != nil {
+		return "", err
+	}
+	return thumbnailKey, nil
+}
+
+func imageContentType(variant string) string {
+	if imageVariantPattern.FindStringSubmatch(variant)[1] == "png" {
+		return "image/png"
+	}
+	return "image/webp"
+}
+
+// Avatars and icons are public, the unguessable hash is the only thing needed to fetch one
+func ServeImage(c *gin.Context) {
+	hash, variant := c.Param("hash"), c.Param("variant")
+	if !imageHashPattern.MatchString(hash) || !imageVariantPattern.MatchString(variant) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	reader, err := storage.Store.Get(c.Request.Context(), "images/"+hash+"/"+variant)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch image"})
+		}
+		return
+	}
+	defer reader.Close()
+
+	// The URL changes whenever the content does, so caches can hold on forever
+	c.DataFromReader(http.StatusOK, -1, imageContentType(variant), reader, map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Cache-Control":          "public, max-age=31536000, immutable",
+	})
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+const (
+	inviteCodeLength     = 8
+	defaultInviteMaxAge  = 24 * 60 * 60 // 24 hours
+	maxInviteCodeRetries = 5
+)
+
+var errInviteUnusable = errors.New("invite is no longer valid")
+
+type CreateInvitePayload struct {
+	ChannelID *uint64 `json:"channel_id,string"`
+	MaxAge    *int    `json:"max_age" binding:"omitempty,min=0,max=604800"` // Seconds, 0 means never
+	MaxUses   int     `json:"max_uses" binding:"omitempty,min=0,max=100"`   // 0 means unlimited
+	Temporary bool    `json:"temporary"`
+}
+
+// InvitePreview is what a non-member sees when resolving a code
+type InvitePreview struct {
+	models.Invite
+	ServerName  string `json:"server_name"`
+	ServerIcon  string `json:"server_icon_url"`
+	MemberCount int64  `json:"member_count"`
+}
+
+func CreateInvite(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var payload CreateInvitePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The target channel has to live in this server
+	if payload.ChannelID != nil {
+		var channel models.Channel
+		if err := database.DB.Where("id = ? AND server_id = ?", *payload.ChannelID, serverID).First(&channel).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+			return
+		}
+	}
+
+	maxAge := defaultInviteMaxAge
+	if payload.MaxAge != nil {
+		maxAge = *payload.MaxAge
+	}
+
+	invite := models.Invite{
+		ServerID:  serverID,
+		ChannelID: payload.ChannelID,
+		CreatorID: userID,
+		MaxUses:   payload.MaxUses,
+		Temporary: payload.Temporary,
+	}
+	if maxAge > 0 {
+		expiresAt := time.Now().Add(time.Duration(maxAge) * time.Second)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	// Codes are random, so retry on the rare collision instead of failing outright
+	for attempt := 0; attempt < maxInviteCodeRetries; attempt++ {
+		invite.Code, err = utils.GenerateInviteCode(inviteCodeLength)
+		if err != nil {
+			break
+		}
+		if err = database.DB.Create(&invite).Error; err == nil {
+			break
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	database.DB.Preload("Creator").First(&invite, "code = ?", invite.Code)
+
+	writeAuditLog(c, serverID, models.AuditInviteCreate, "invite", invite.Code, models.AuditChanges{
+		{Key: "max_uses", New: invite.MaxUses},
+		{Key: "expires_at", New: invite.ExpiresAt},
+		{Key: "temporary", New: invite.Temporary},
+	})
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+func ListInvites(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	// Revoked invites are kept for history but hidden from the list
+	var invites []models.Invite
+	if err := database.DB.Preload("Creator").
+		Where("server_id = ? AND revoked_at IS NULL", serverID).
+		Order("created_at desc").
+		Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+func RevokeInvite(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var invite models.Invite
+	if err := database.DB.Where("code = ? AND server_id = ? AND revoked_at IS NULL", c.Param("code"), serverID).First(&invite).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	if err := database.DB.Model(&invite).Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditInviteDelete, "invite", invite.Code, models.AuditChanges{
+		{Key: "uses", Old: invite.Uses},
+	})
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func ResolveInvite(c *gin.Context) {
+	var invite models.Invite
+	// The inner join drops invites to servers that have been deleted
+	if err := database.DB.Preload("Creator").InnerJoins("Server").
+		Where("invites.code = ?", c.Param("code")).
+		First(&invite).Error; err != nil || !invite.IsUsable() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite is invalid or has expired"})
+		return
+	}
+
+	var memberCount int64
+	database.DB.Model(&models.ServerMember{}).
+		Where("server_id = ? AND left_at IS NULL", invite.ServerID).
+		Count(&memberCount)
+
+	c.JSON(http.StatusOK, InvitePreview{
+		Invite:      invite,
+		ServerName:  invite.Server.Name,
+		ServerIcon:  invite.Server.IconURL,
+		MemberCount: memberCount,
+	})
+}
+
+func AcceptInvite(c *gin.Context) {
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var invite models.Invite
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Invites made before the server was deleted must not let anyone back in
+		if err := tx.Preload("Creator").InnerJoins("Server").Where("invites.code = ?", c.Param("code")).First(&invite).Error; err != nil {
+			return errInviteUnusable
+		}
+		if !invite.IsUsable() {
+			return errInviteUnusable
+		}
+
+		if err := addServerMember(tx, invite.ServerID, userID, invite.Temporary); err != nil {
+			return err
+		}
+
+		// Guard the counter in SQL so two racing joins can't both take the last use
+		result := tx.Model(&models.Invite{}).
+			Where("code = ? AND (max_uses = 0 OR uses < max_uses)", invite.Code).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInviteUnusable
+		}
+		invite.Uses++
+		return nil
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(err, errInviteUnusable):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invite is invalid or has expired"})
+		case errors.Is(err, errAlreadyMember):
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this server"})
+		case errors.Is(err, errBanned):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this server"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join server"})
+		}
+		return
+	}
+
+	announceMemberJoin(invite.ServerID, userID)
+
+	// Hand back the invite so the client knows which server and channel to open
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Successfully joined the server",
+		"invite":  invite,
+	})
+}
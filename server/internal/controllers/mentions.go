@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/readstates"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+var (
+	userMentionPattern     = regexp.MustCompile(`<@(\d+)>`)
+	roleMentionPattern     = regexp.MustCompile(`<@&(\d+)>`)
+	everyoneMentionPattern = regexp.MustCompile(`(?:^|\W)@(everyone|here)\b`)
+)
+
+// mentionSet is who a message pings
+type mentionSet struct {
+	UserIDs  []uint64
+	RoleIDs  []uint64
+	Everyone bool
+	Here     bool // Only people who are online right now
+}
+
+// Pulls the mention tokens out of message content, nothing is checked yet
+func parseMentions(content string) mentionSet {
+	var mentions mentionSet
+	mentions.UserIDs = matchIDs(userMentionPattern, content)
+	mentions.RoleIDs = matchIDs(roleMentionPattern, content)
+	for _, match := range everyoneMentionPattern.FindAllStringSubmatch(content, -1) {
+		if match[1] == "everyone" {
+			mentions.Everyone = true
+		} else {
+			mentions.Here = true
+		}
+	}
+	return mentions
+}
+
+// Collects the unique IDs captured by a mention pattern
+func matchIDs(pattern *regexp.Regexp, content string) []uint64 {
+	seen := make(map[uint64]bool)
+	var ids []uint64
+	for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+		id, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Parses the content and drops any mention that doesn't count: users who aren't in the
+// server (or DM), roles from elsewhere, and @everyone/@here without the permission for it.
+// Anything dropped just stays as plain text.
+func resolveMentions(serverID uint64, channelID uint64, content string, perms models.Permission) mentionSet {
+	mentions := parseMentions(content)
+
+	// DMs only have their recipients to ping
+	if serverID == 0 {
+		var recipients []uint64
+		if len(mentions.UserIDs) > 0 {
+			database.DB.Model(&models.DMRecipient{}).
+				Where("channel_id = ? AND user_id IN ?", channelID, mentions.UserIDs).
+				Pluck("user_id", &recipients)
+		}
+		return mentionSet{UserIDs: recipients}
+	}
+
+	if len(mentions.UserIDs) > 0 {
+		var members []uint64
+		database.DB.Model(&models.ServerMember{}).
+			Where("server_id = ? AND user_id IN ? AND left_at IS NULL", serverID, mentions.UserIDs).
+			Pluck("user_id", &members)
+		mentions.UserIDs = members
+	}
+
+	// @everyone is its own token, its role can't be pinged by ID
+	if len(mentions.RoleIDs) > 0 {
+		var roles []uint64
+		database.DB.Model(&models.Role{}).
+			Where("server_id = ? AND id IN ? AND is_default = ?", serverID, mentions.RoleIDs, false).
+			Pluck("id", &roles)
+		mentions.RoleIDs = roles
+	}
+
+	if !perms.Has(models.PermissionMentionEveryone) {
+		mentions.Everyone = false
+		mentions.Here = false
+	}
+
+	return mentions
+}
+
+// Copies the mentions onto a message so they're saved with it
+func applyMentions(message *models.Message, mentions mentionSet) {
+	message.Mentions = nil
+	for _, userID := range mentions.UserIDs {
+		message.Mentions = append(message.Mentions, models.MessageMention{MessageID: message.ID, UserID: userID})
+	}
+	message.MentionRoles = nil
+	for _, roleID := range mentions.RoleIDs {
+		message.MentionRoles = append(message.MentionRoles, models.MessageRoleMention{MessageID: message.ID, RoleID: roleID})
+	}
+	message.MentionEveryone = mentions.Everyone || mentions.Here
+}
+
+// Rebuilds the mention set from what was stored on a message.
+// Stored @here can't be told apart from @everyone, so it's treated as the wider of the two.
+func storedMentions(message models.Message) mentionSet {
+	mentions := mentionSet{Everyone: message.MentionEveryone}
+	for _, mention := range message.Mentions {
+		mentions.UserIDs = append(mentions.UserIDs, mention.UserID)
+	}
+	for _, mention := range message.MentionRoles {
+		mentions.RoleIDs = append(mentions.RoleIDs, mention.RoleID)
+	}
+	return mentions
+}
+
+// Works out every user a set of mentions reaches. Only people who can see the
+// channel count, and authors never ping themselves.
+func mentionTargets(serverID uint64, channelID uint64, authorID uint64, mentions mentionSet) map[uint64]bool {
+	targets := make(map[uint64]bool)
+	for _, userID := range mentions.UserIDs {
+		targets[userID] = true
+	}
+
+	if serverID != 0 {
+		if len(mentions.RoleIDs) > 0 {
+			var holders []uint64
+			database.DB.Model(&models.MemberRole{}).
+				Where("server_id = ? AND role_id IN ?", serverID, mentions.RoleIDs).
+				Where("user_id IN (?)", database.DB.Model(&models.ServerMember{}).Select("user_id").Where("server_id = ? AND left_at IS NULL", serverID)).
+				Pluck("user_id", &holders)
+			for _, userID := range holders {
+				targets[userID] = true
+			}
+		}
+
+		if mentions.Everyone || mentions.Here {
+			var members []uint64
+			database.DB.Model(&models.ServerMember{}).
+				Where("server_id = ? AND left_at IS NULL", serverID).
+				Pluck("user_id", &members)
+
+			// A nil set means everyone, @here narrows it to live connections
+			var online map[uint64]bool
+			if !mentions.Everyone {
+				online = websockets.Manager.OnlineUsers(members)
+			}
+			for _, userID := range members {
+				if online == nil || online[userID] {
+					targets[userID] = true
+				}
+			}
+		}
+
+		viewers, err := permissions.ChannelViewers(serverID, channelID)
+		if err != nil {
+			// Fail closed, same as broadcasts
+			log.Printf("Failed to resolve viewers for channel %d: %v", channelID, err)
+			return nil
+		}
+		if viewers != nil {
+			for userID := range targets {
+				if !viewers[userID] {
+					delete(targets, userID)
+				}
+			}
+		}
+	}
+
+	delete(targets, authorID)
+	return targets
+}
+
+// Pings each mentioned user on every connection they have, whichever channel they're looking at
+func notifyMentions(serverID uint64, message models.Message, targets map[uint64]bool) {
+	// An empty target list would fall back to the whole server room
+	if len(targets) == 0 {
+		return
+	}
+
+	userIDs := make([]uint64, 0, len(targets))
+	for userID := range targets {
+		userIDs = append(userIDs, userID)
+	}
+
+	// Badges have to survive a restart, so the count is stored before anyone is told
+	if err := readstates.AddMention(message.ChannelID, userIDs); err != nil {
+		log.Printf("Failed to update mention counts in channel %d: %v", message.ChannelID, err)
+	}
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID:  serverID,
+		TargetChannelID: message.ChannelID,
+		Event:           "MENTION_CREATE",
+		Data:            message,
+		TargetUserIDs:   userIDs,
+	}
+}
@@ -1,30 +1,149 @@
 package controllers
 
 import (
+	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
 	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/ratelimit"
+	"github.com/jonahgcarpenter/hermes/server/internal/readstates"
+	"github.com/jonahgcarpenter/hermes/server/internal/storage"
 	"github.com/jonahgcarpenter/hermes/server/internal/utils"
 	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
 )
 
-// Helper function to verify the channelID provided in the URL actually belongs to the serverID
+// Helper function to verify the channelID provided in the URL actually belongs to the serverID.
+// DM routes have no serverID, RequireDMRecipient has already checked those and the server ID comes back as 0.
 func verifyChannel(c *gin.Context) (uint64, uint64, error) {
 	serverID, _ := strconv.ParseUint(c.Param("serverID"), 10, 64)
 	channelID, _ := strconv.ParseUint(c.Param("channelID"), 10, 64)
 
+	if c.Param("serverID") == "" {
+		var channel models.Channel
+		if err := database.DB.Where("id = ? AND type IN ?", channelID,
+			[]models.ChannelType{models.ChannelTypeDM, models.ChannelTypeGroupDM}).First(&channel).Error; err != nil {
+			return 0, 0, err
+		}
+		return 0, channelID, nil
+	}
+
 	var channel models.Channel
-	// Ensure the channel exists AND belongs to the server in the URL path
-	if err := database.DB.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+	// Ensure the channel exists AND belongs to the server in the URL path.
+	// Categories have no messages, and forums keep theirs in their posts.
+	if err := database.DB.Where("id = ? AND server_id = ? AND type NOT IN ?", channelID, serverID,
+		[]models.ChannelType{models.ChannelTypeCategory, models.ChannelTypeForum}).
+		First(&channel).Error; err != nil {
 		return 0, 0, err
 	}
 
 	return serverID, channelID, nil
 }
 
+const (
+	defaultMessageLimit = 50
+	maxMessageLimit     = 100
+)
+
+// MessagePage is a window of channel history ordered oldest to newest
+type MessagePage struct {
+	Messages      []models.Message `json:"messages"`
+	HasMoreBefore bool             `json:"has_more_before"`
+	HasMoreAfter  bool             `json:"has_more_after"`
+}
+
+// Helper to read an optional snowflake cursor from the query string
+func parseCursor(c *gin.Context, key string) (uint64, bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	return id, true, err
+}
+
+// Preloads everything a message is sent to clients with
+func withMessageRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Author").
+		Preload("ReferencedMessage.Author").
+		Preload("Thread").
+		Preload("Attachments").
+		Preload("Mentions.User").
+		Preload("MentionRoles")
+}
+
+// Fetches up to limit messages on one side of the anchor.
+// Asks for one extra row so we know if there is more beyond the page.
+func fetchMessageSlice(channelID uint64, condition string, anchor uint64, order string, limit int) ([]models.Message, bool, error) {
+	query := withMessageRelations(database.DB).Where("channel_id = ?", channelID)
+	if condition != "" {
+		query = query.Where(condition, anchor)
+	}
+
+	var messages []models.Message
+	if err := query.Order(order).Limit(limit + 1).Find(&messages).Error; err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	return messages, hasMore, nil
+}
+
+// Checks if any message exists on the other side of a cursor
+func messagesExist(channelID uint64, condition string, anchor uint64) bool {
+	var count int64
+	database.DB.Model(&models.Message{}).Where("channel_id = ?", channelID).Where(condition, anchor).Count(&count)
+	return count > 0
+}
+
+// Flips a newest-first slice so pages are always returned oldest-first
+func reverseMessages(messages []models.Message) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// Helper to grab the overwrite-adjusted permissions set by RequireChannelPermission
+func currentChannelPermissions(c *gin.Context) models.Permission {
+	permsObj, _ := c.Get("channel_permissions")
+	return permsObj.(models.Permission)
+}
+
+// Broadcasts a channel event only to the members allowed to see that channel
+func broadcastToChannel(serverID uint64, channelID uint64, event string, data interface{}) {
+	// DMs don't belong to a server room, send to each participant instead
+	if serverID == 0 {
+		broadcastToDM(channelID, event, data)
+		return
+	}
+
+	viewers, err := permissions.ChannelViewers(serverID, channelID)
+	if err != nil {
+		// Fail closed, a missed live update is better than leaking a private channel
+		log.Printf("Failed to resolve viewers for channel %d: %v", channelID, err)
+		return
+	}
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID:  serverID,
+		TargetChannelID: channelID,
+		Event:           event,
+		Data:            data,
+		VisibleTo:       viewers,
+	}
+}
+
 func ListMessages(c *gin.Context) {
 	_, channelID, err := verifyChannel(c)
 	if err != nil {
@@ -32,22 +151,87 @@ func ListMessages(c *gin.Context) {
 		return
 	}
 
-	var messages []models.Message
-	// Preload author so the frontend gets the author's username and avatar right away,
-	if err := database.DB.Preload("Author").
-		Where("channel_id = ?", channelID).
-		Order("created_at asc").
-		Limit(50).
-		Find(&messages).Error; err != nil {
+	limit := defaultMessageLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxMessageLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxMessageLimit)})
+			return
+		}
+	}
+
+	before, hasBefore, errBefore := parseCursor(c, "before")
+	after, hasAfter, errAfter := parseCursor(c, "after")
+	around, hasAround, errAround := parseCursor(c, "around")
+	if errBefore != nil || errAfter != nil || errAround != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	// Only one anchor makes sense at a time
+	anchors := 0
+	for _, set := range []bool{hasBefore, hasAfter, hasAround} {
+		if set {
+			anchors++
+		}
+	}
+	if anchors > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only one of before, after or around may be provided"})
+		return
+	}
+
+	// Snowflake IDs are time ordered, so sorting by ID is sorting by send time
+	page := MessagePage{}
+	switch {
+	case hasBefore:
+		page.Messages, page.HasMoreBefore, err = fetchMessageSlice(channelID, "id < ?", before, "id desc", limit)
+		reverseMessages(page.Messages)
+		page.HasMoreAfter = messagesExist(channelID, "id >= ?", before)
+
+	case hasAfter:
+		page.Messages, page.HasMoreAfter, err = fetchMessageSlice(channelID, "id > ?", after, "id asc", limit)
+		page.HasMoreBefore = messagesExist(channelID, "id <= ?", after)
+
+	case hasAround:
+		// Split the window, the anchor message itself counts towards the newer half
+		olderLimit := limit / 2
+		var older, newer []models.Message
+		older, page.HasMoreBefore, err = fetchMessageSlice(channelID, "id < ?", around, "id desc", olderLimit)
+		if err == nil {
+			newer, page.HasMoreAfter, err = fetchMessageSlice(channelID, "id >= ?", around, "id asc", limit-olderLimit)
+		}
+		reverseMessages(older)
+		page.Messages = append(older, newer...)
+
+	default:
+		// No anchor means the most recent messages in the channel
+		page.Messages, page.HasMoreBefore, err = fetchMessageSlice(channelID, "", 0, "id desc", limit)
+		reverseMessages(page.Messages)
+	}
+
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
 		return
 	}
 
-	c.JSON(http.StatusOK, messages)
+	// Always return an array (not null) so clients can append safely
+	if page.Messages == nil {
+		page.Messages = []models.Message{}
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := attachReactions(page.Messages, userIDObj.(uint64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
+// Sent as JSON, or as multipart/form-data with the same fields plus "files"
 type SendMessagePayload struct {
-	Content string `json:"content" binding:"required,min=1,max=2000"`
+	Content             string  `json:"content" form:"content" binding:"max=2000"`
+	ReferencedMessageID *uint64 `json:"referenced_message_id,string" form:"referenced_message_id"`
 }
 
 func SendMessage(c *gin.Context) {
@@ -57,41 +241,128 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// Checked before the body is read so a flood doesn't cost us the uploads
+	if ok, retryAfter := ratelimit.Messages.Allow(strconv.FormatUint(userID, 10)); !ok {
+		rateLimitedResponse(c, retryAfter, "You are sending messages too quickly")
+		return
+	}
+
+	// Cap the whole body so an oversized upload is cut off instead of spooled to disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentsPerMessage*storage.MaxFileSize+1<<20)
+
 	var payload SendMessagePayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBind(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	userIDObj, _ := c.Get("user_id")
-	userID := userIDObj.(uint64)
+	files := uploadedFiles(c)
+	if payload.Content == "" && len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A message needs content or at least one attachment"})
+		return
+	}
+
+	// DM routes don't set a channel, they can't have threads or slow mode anyway
+	var channel models.Channel
+	if channelObj, ok := c.Get("channel"); ok {
+		channel = channelObj.(models.Channel)
+	}
+
+	// Replies have to point at a live message in the same channel
+	if payload.ReferencedMessageID != nil {
+		var count int64
+		database.DB.Model(&models.Message{}).
+			Where("id = ? AND channel_id = ?", *payload.ReferencedMessageID, channelID).
+			Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Referenced message not found in this channel"})
+			return
+		}
+	}
 
 	// Create the message object
 	message := models.Message{
-		ID:        utils.GenerateID(),
-		ChannelID: channelID,
-		AuthorID:  userID,
-		Content:   payload.Content,
+		ID:                  utils.GenerateID(),
+		ChannelID:           channelID,
+		AuthorID:            &userID,
+		Content:             payload.Content,
+		ReferencedMessageID: payload.ReferencedMessageID,
+	}
+	perms := currentChannelPermissions(c)
+	if channel.Locked && !perms.Has(models.PermissionManageChannels) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This thread is locked"})
+		return
+	}
+
+	mentions := resolveMentions(serverID, channelID, payload.Content, perms)
+	applyMentions(&message, mentions)
+
+	// Slow mode is started here, anything that fails from now on gives the turn back
+	slowModeKey := ""
+	if channel.SlowModeSeconds > 0 && !perms.Has(models.PermissionBypassSlowMode) {
+		slowModeKey = strconv.FormatUint(channelID, 10) + ":" + strconv.FormatUint(userID, 10)
+		if ok, retryAfter := ratelimit.SlowMode.Take(slowModeKey, time.Duration(channel.SlowModeSeconds)*time.Second); !ok {
+			rateLimitedResponse(c, retryAfter, "This channel is in slow mode")
+			return
+		}
+	}
+
+	// Files go to storage first, their rows are saved along with the message
+	if len(files) > 0 {
+		message.Attachments, err = storeAttachments(files, serverID, channelID, message.ID, userID)
+		if err != nil {
+			ratelimit.SlowMode.Clear(slowModeKey)
+			attachmentErrorResponse(c, err)
+			return
+		}
 	}
 
 	// Save to the database
 	if err := database.DB.Create(&message).Error; err != nil {
+		ratelimit.SlowMode.Clear(slowModeKey)
+		removeStoredAttachments(message.Attachments)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save message"})
 		return
 	}
 
-	// Fetch the message again to populate the Preloaded Author data before broadcasting.
-	database.DB.Preload("Author").First(&message, message.ID)
+	publishMessage(serverID, channel, &message, mentions)
 
-	// Broadcast the new message to the WebSocket Hub so everyone in the channel sees it instantly.
-	websockets.Manager.Broadcast <- websockets.WsMessage{
-		TargetServerID:  serverID,
-		TargetChannelID: channelID,
-		Event:           "MESSAGE_CREATE",
-		Data:            message,
+	c.JSON(http.StatusCreated, message)
+}
+
+// Rejects a request that went over a limit. retry_after is in seconds, like the header.
+func rateLimitedResponse(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := math.Ceil(retryAfter.Seconds()*1000) / 1000
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": seconds})
+}
+
+// Everything that follows a new message being saved. Shared by SendMessage and the scheduler
+// so a scheduled message looks exactly like one sent by hand.
+// Only for messages a user sent, announcement copies go out from PublishMessage.
+func publishMessage(serverID uint64, channel models.Channel, message *models.Message, mentions mentionSet) {
+	authorID := *message.AuthorID
+
+	// Your own message is as far as you've obviously read
+	if _, err := readstates.Ack(authorID, message.ChannelID, message.ID); err != nil {
+		log.Printf("Failed to move read marker for user %d in channel %d: %v", authorID, message.ChannelID, err)
 	}
 
-	c.JSON(http.StatusCreated, message)
+	// Talking in a thread keeps it alive and follows it for you
+	if channel.IsThread() {
+		touchThread(channel, authorID)
+	}
+
+	// Fetch the message again to populate the Preloaded Author data before broadcasting.
+	withMessageRelations(database.DB).First(message, message.ID)
+
+	// Broadcast the new message to the WebSocket Hub so everyone in the channel sees it instantly.
+	broadcastToChannel(serverID, message.ChannelID, "MESSAGE_CREATE", *message)
+	notifyMentions(serverID, *message, mentionTargets(serverID, message.ChannelID, authorID, mentions))
+	queueEmbeds(serverID, *message)
 }
 
 type EditMessagePayload struct {
@@ -120,7 +391,8 @@ func EditMessage(c *gin.Context) {
 
 	// Fetch the original message
 	var message models.Message
-	if err := database.DB.Where("id = ? AND channel_id = ?", messageID, channelID).First(&message).Error; err != nil {
+	if err := database.DB.Preload("Mentions").Preload("MentionRoles").
+		Where("id = ? AND channel_id = ?", messageID, channelID).First(&message).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
 		return
 	}
@@ -129,31 +401,115 @@ func EditMessage(c *gin.Context) {
 	userIDObj, _ := c.Get("user_id")
 	userID := userIDObj.(uint64)
 
-	if message.AuthorID != userID {
+	if !message.SentBy(userID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own messages"})
 		return
 	}
 
-	// Update the specific field in the database
-	if err := database.DB.Model(&message).Update("content", payload.Content).Error; err != nil {
+	// A published copy stays the way the announcement was when it went out
+	if message.SourceMessageID != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Published announcements cannot be edited here"})
+		return
+	}
+
+	// Mentions follow the new content, only people who weren't already pinged hear about it
+	previousTargets := mentionTargets(serverID, channelID, userID, storedMentions(message))
+	mentions := resolveMentions(serverID, channelID, payload.Content, currentChannelPermissions(c))
+	applyMentions(&message, mentions)
+
+	updates := map[string]interface{}{"content": payload.Content, "mention_everyone": message.MentionEveryone}
+	changed := payload.Content != message.Content
+	if changed {
+		// Old previews go now, the new content gets its own once they're fetched
+		updates["edited_at"] = time.Now()
+		updates["embeds"] = models.Embeds{}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Keep the old content for moderators. DMs have none, so there's no one to keep it for.
+		if changed && serverID != 0 {
+			revision := models.MessageRevision{ID: utils.GenerateID(), MessageID: message.ID, Content: message.Content}
+			if err := tx.Create(&revision).Error; err != nil {
+				return err
+			}
+		}
+
+		// Bare model so the mention rows don't get upserted from the loaded message
+		if err := tx.Model(&models.Message{}).Where("id = ?", message.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageMention{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageRoleMention{}).Error; err != nil {
+			return err
+		}
+		if len(message.Mentions) > 0 {
+			if err := tx.Create(&message.Mentions).Error; err != nil {
+				return err
+			}
+		}
+		if len(message.MentionRoles) > 0 {
+			if err := tx.Create(&message.MentionRoles).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update message"})
 		return
 	}
 
 	// Preload the author again so the broadcast contains the full object
-	database.DB.Preload("Author").First(&message, message.ID)
+	withMessageRelations(database.DB).First(&message, message.ID)
 
 	// Broadcast the UPDATE event to the WebSocket Hub.
-	websockets.Manager.Broadcast <- websockets.WsMessage{
-		TargetServerID:  serverID,
-		TargetChannelID: channelID,
-		Event:           "MESSAGE_UPDATE",
-		Data:            message,
+	broadcastToChannel(serverID, channelID, "MESSAGE_UPDATE", message)
+
+	newTargets := mentionTargets(serverID, channelID, userID, mentions)
+	for target := range previousTargets {
+		delete(newTargets, target)
+	}
+	notifyMentions(serverID, message, newTargets)
+	if changed {
+		queueEmbeds(serverID, message)
 	}
 
 	c.JSON(http.StatusOK, message)
 }
 
+// ListMessageRevisions shows moderators what a message said before each edit, oldest first
+func ListMessageRevisions(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	// Deleted messages count too, that's usually when history matters most
+	var count int64
+	database.DB.Unscoped().Model(&models.Message{}).Where("id = ? AND channel_id = ?", messageID, channelID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	revisions := []models.MessageRevision{}
+	if err := database.DB.Where("message_id = ?", messageID).Order("id asc").Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
 func DeleteMessage(c *gin.Context) {
 	serverID, channelID, err := verifyChannel(c)
 	if err != nil {
@@ -173,35 +529,213 @@ func DeleteMessage(c *gin.Context) {
 		return
 	}
 
-	// Grab user identity and their server role from the Gin context
+	// Grab user identity and their channel permissions from the Gin context
 	userIDObj, _ := c.Get("user_id")
 	userID := userIDObj.(uint64)
-
-	// c.GetString safely returns an empty string if it isn't found
-	serverRole := c.GetString("server_role")
+	channelPerms := currentChannelPermissions(c)
 
 	// Can this user delete this message?
-	// They must either be the Author, OR have admin/owner privileges in this server.
-	if message.AuthorID != userID && serverRole != "admin" && serverRole != "owner" {
+	// They must either be the Author, OR be allowed to manage messages in this channel.
+	if !message.SentBy(userID) && !channelPerms.Has(models.PermissionManageMessages) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to delete this message"})
 		return
 	}
 
-	// Soft delete
-	if err := database.DB.Delete(&message).Error; err != nil {
+	// Soft delete. Deleting also unpins so a restore can't push the channel past its pin cap.
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if message.PinnedAt != nil {
+			if err := tx.Model(&models.Message{}).Where("id = ?", message.ID).Update("pinned_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&message).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete message"})
 		return
 	}
 
+	// Only moderators removing someone else's message are worth auditing
+	if !message.SentBy(userID) {
+		writeAuditLog(c, serverID, models.AuditMessageDelete, "message", idString(message.ID), models.AuditChanges{
+			{Key: "author_id", Old: optionalIDString(message.AuthorID)},
+			{Key: "channel_id", Old: idString(channelID)},
+			{Key: "content", Old: message.Content},
+		})
+	}
+
 	// Broadcast the DELETE event.
 	deletePayload := gin.H{"id": strconv.FormatUint(messageID, 10)}
 
-	websockets.Manager.Broadcast <- websockets.WsMessage{
-		TargetServerID:  serverID,
-		TargetChannelID: channelID,
-		Event:           "MESSAGE_DELETE",
-		Data:            deletePayload,
+	broadcastToChannel(serverID, channelID, "MESSAGE_DELETE", deletePayload)
+	if message.PinnedAt != nil {
+		broadcastPinsUpdate(serverID, channelID)
 	}
 
 	c.JSON(http.StatusNoContent, nil) // 204 No Content is the standard for a successful delete
 }
+
+// Messages a filter can take out at once, run it again for more
+const maxBulkDeleteMatch = 1000
+
+// BulkDeletePayload takes either a list of message IDs or a filter, not both
+type BulkDeletePayload struct {
+	MessageIDs []string `json:"message_ids" binding:"omitempty,max=100"`
+
+	AuthorID *uint64   `json:"author_id,string"`
+	Before   time.Time `json:"before"`
+	After    time.Time `json:"after"`
+	Contains string    `json:"contains" binding:"omitempty,max=2000"`
+}
+
+// Escapes LIKE wildcards so user text is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// BulkDeleteMessages soft deletes many messages in a channel at once for cleaning up spam.
+// Everything goes in one transaction and clients get a single MESSAGE_DELETE_BULK event.
+func BulkDeleteMessages(c *gin.Context) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	var payload BulkDeletePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasFilter := payload.AuthorID != nil || !payload.Before.IsZero() || !payload.After.IsZero() || payload.Contains != ""
+	if len(payload.MessageIDs) > 0 && hasFilter {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Send either message_ids or a filter, not both"})
+		return
+	}
+	if len(payload.MessageIDs) == 0 && !hasFilter {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide message_ids or at least one filter"})
+		return
+	}
+
+	query := database.DB.Model(&models.Message{}).Where("channel_id = ?", channelID)
+	changes := models.AuditChanges{{Key: "channel_id", Old: idString(channelID)}}
+
+	if len(payload.MessageIDs) > 0 {
+		ids := make([]uint64, 0, len(payload.MessageIDs))
+		for _, raw := range payload.MessageIDs {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+				return
+			}
+			ids = append(ids, id)
+		}
+		// IDs from other channels or already deleted are just skipped
+		query = query.Where("id IN ?", ids)
+	} else {
+		if payload.AuthorID != nil {
+			query = query.Where("author_id = ?", *payload.AuthorID)
+			changes = append(changes, models.AuditChange{Key: "author_id", Old: idString(*payload.AuthorID)})
+		}
+		if !payload.Before.IsZero() {
+			query = query.Where("created_at < ?", payload.Before)
+			changes = append(changes, models.AuditChange{Key: "before", Old: payload.Before})
+		}
+		if !payload.After.IsZero() {
+			query = query.Where("created_at > ?", payload.After)
+			changes = append(changes, models.AuditChange{Key: "after", Old: payload.After})
+		}
+		if payload.Contains != "" {
+			query = query.Where(`LOWER(content) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(payload.Contains))+"%")
+			changes = append(changes, models.AuditChange{Key: "contains", Old: payload.Contains})
+		}
+		// Newest first, that's where a spam wave usually is
+		query = query.Order("id desc").Limit(maxBulkDeleteMatch)
+	}
+
+	var targets []models.Message
+	if err := query.Select("id", "pinned_at").Find(&targets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find messages"})
+		return
+	}
+	if len(targets) == 0 {
+		c.JSON(http.StatusOK, gin.H{"deleted": 0})
+		return
+	}
+
+	ids := make([]uint64, len(targets))
+	deletedIDs := make([]string, len(targets))
+	hadPins := false
+	for i, message := range targets {
+		ids[i] = message.ID
+		deletedIDs[i] = idString(message.ID)
+		hadPins = hadPins || message.PinnedAt != nil
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error { return deleteMessages(tx, ids) })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete messages"})
+		return
+	}
+
+	changes = append(changes, models.AuditChange{Key: "count", Old: len(ids)})
+	writeAuditLog(c, serverID, models.AuditMessageBulkDelete, "channel", idString(channelID), changes)
+
+	broadcastToChannel(serverID, channelID, "MESSAGE_DELETE_BULK", gin.H{
+		"channel_id": idString(channelID),
+		"ids":        deletedIDs,
+	})
+	if hadPins {
+		broadcastPinsUpdate(serverID, channelID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": len(ids)})
+}
+
+// Soft deletes messages, given as an ID list or a subquery selecting IDs. Same as a
+// single delete, pins come off so a restore can't overflow the cap.
+func deleteMessages(tx *gorm.DB, messages interface{}) error {
+	if err := tx.Model(&models.Message{}).Where("id IN (?) AND pinned_at IS NOT NULL", messages).Update("pinned_at", nil).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN (?)", messages).Delete(&models.Message{}).Error
+}
+
+func RestoreMessage(c *gin.Context) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	// Only messages still inside the retention window can come back
+	var message models.Message
+	if err := database.DB.Unscoped().
+		Where("id = ? AND channel_id = ? AND deleted_at > ?", messageID, channelID, time.Now().Add(-database.Retention)).
+		First(&message).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No restorable message found"})
+		return
+	}
+
+	if err := database.DB.Unscoped().Model(&message).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore message"})
+		return
+	}
+
+	withMessageRelations(database.DB).First(&message, message.ID)
+
+	writeAuditLog(c, serverID, models.AuditMessageRestore, "message", idString(message.ID), models.AuditChanges{
+		{Key: "author_id", New: optionalIDString(message.AuthorID)},
+		{Key: "channel_id", New: idString(channelID)},
+	})
+
+	// Clients slot it back into history by its snowflake ID
+	broadcastToChannel(serverID, channelID, "MESSAGE_CREATE", message)
+
+	c.JSON(http.StatusOK, message)
+}
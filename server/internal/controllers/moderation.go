@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/webrtc"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+// Helper to extract "userID" from URL
+func parseTargetUserID(c *gin.Context) (uint64, error) {
+	return strconv.ParseUint(c.Param("userID"), 10, 64)
+}
+
+// Checks that the acting moderator sits above the target in the role hierarchy.
+// Writes the error response itself and returns nil if the action isn't allowed.
+func resolveModerationTarget(c *gin.Context, serverID uint64, targetUserID uint64) *permissions.Member {
+	actor := currentMember(c)
+
+	if targetUserID == actor.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot moderate yourself"})
+		return nil
+	}
+
+	target, err := permissions.Resolve(serverID, targetUserID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return nil
+	}
+
+	if !actor.Outranks(target) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot moderate a member with an equal or higher role"})
+		return nil
+	}
+
+	return target
+}
+
+// Marks the member as gone and cuts off their live connections to the server
+func removeMember(db *gorm.DB, serverID uint64, userID uint64) error {
+	now := time.Now()
+	return db.Model(&models.ServerMember{}).
+		Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, userID).
+		Updates(map[string]interface{}{"left_at": &now, "timeout_until": nil}).Error
+}
+
+// Runs after a kick or ban has been committed
+func announceMemberRemoval(serverID uint64, userID uint64) {
+	// Instantly cut off WebSocket events and voice for this server
+	websockets.Manager.LeaveRoom <- websockets.RoomUpdate{
+		UserID:   userID,
+		ServerID: serverID,
+	}
+	webrtc.DisconnectFromServer(userID, serverID)
+
+	// Broadcast departure to remaining members
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "SERVER_MEMBER_REMOVE",
+		Data:           gin.H{"user_id": strconv.FormatUint(userID, 10)},
+	}
+}
+
+func KickMember(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	targetUserID, err := parseTargetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if resolveModerationTarget(c, serverID, targetUserID) == nil {
+		return
+	}
+
+	if err := removeMember(database.DB, serverID, targetUserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to kick member"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberKick, "member", idString(targetUserID), nil)
+
+	announceMemberRemoval(serverID, targetUserID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func ListBans(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var bans []models.Ban
+	if err := database.DB.Preload("User").
+		Where("server_id = ?", serverID).
+		Order("created_at desc").
+		Find(&bans).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bans"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bans)
+}
+
+type BanMemberPayload struct {
+	Reason            string `json:"reason" binding:"omitempty,max=512"`
+	DeleteMessageDays int    `json:"delete_message_days" binding:"omitempty,min=0,max=7"`
+}
+
+func BanMember(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	targetUserID, err := parseTargetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var payload BanMemberPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor := currentMember(c)
+
+	// Users who aren't members can still be banned pre-emptively,
+	// but current members must rank below the moderator.
+	isMember := false
+	if _, err := permissions.Resolve(serverID, targetUserID); err == nil {
+		if resolveModerationTarget(c, serverID, targetUserID) == nil {
+			return
+		}
+		isMember = true
+	} else {
+		var user models.User
+		if err := database.DB.Select("id").First(&user, targetUserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+	}
+
+	ban := models.Ban{
+		ServerID:    serverID,
+		UserID:      targetUserID,
+		ModeratorID: actor.UserID,
+		Reason:      payload.Reason,
+	}
+
+	// Channel ID -> deleted message IDs, so each channel gets one bulk event
+	purged := make(map[uint64][]string)
+	unpinned := make(map[uint64]bool)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&ban).Error; err != nil {
+			return err
+		}
+
+		if err := removeMember(tx, serverID, targetUserID); err != nil {
+			return err
+		}
+
+		if payload.DeleteMessageDays == 0 {
+			return nil
+		}
+
+		// A subquery rather than an ID list, a week of spam can outgrow the bind parameter limit
+		cutoff := time.Now().Add(-time.Duration(payload.DeleteMessageDays) * 24 * time.Hour)
+		targets := tx.Model(&models.Message{}).Select("id").
+			Where("author_id = ? AND created_at >= ? AND channel_id IN (?)", targetUserID, cutoff,
+				tx.Model(&models.Channel{}).Select("id").Where("server_id = ?", serverID))
+
+		var messages []models.Message
+		if err := tx.Select("id", "channel_id", "pinned_at").Where("id IN (?)", targets).Find(&messages).Error; err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		for _, m := range messages {
+			purged[m.ChannelID] = append(purged[m.ChannelID], strconv.FormatUint(m.ID, 10))
+			if m.PinnedAt != nil {
+				unpinned[m.ChannelID] = true
+			}
+		}
+		return deleteMessages(tx, targets)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban member"})
+		return
+	}
+
+	if isMember {
+		announceMemberRemoval(serverID, targetUserID)
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberBanAdd, "member", idString(targetUserID), models.AuditChanges{
+		{Key: "reason", New: payload.Reason},
+		{Key: "delete_message_days", New: payload.DeleteMessageDays},
+	})
+
+	for channelID, ids := range purged {
+		broadcastToChannel(serverID, channelID, "MESSAGE_DELETE_BULK", gin.H{
+			"channel_id": idString(channelID),
+			"ids":        ids,
+		})
+		if unpinned[channelID] {
+			broadcastPinsUpdate(serverID, channelID)
+		}
+	}
+
+	database.DB.Preload("User").Where("server_id = ? AND user_id = ?", serverID, targetUserID).First(&ban)
+
+	c.JSON(http.StatusOK, ban)
+}
+
+func UnbanMember(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	targetUserID, err := parseTargetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	result := database.DB.Where("server_id = ? AND user_id = ?", serverID, targetUserID).Delete(&models.Ban{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ban not found"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberBanRemove, "member", idString(targetUserID), nil)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+type TimeoutMemberPayload struct {
+	DurationSeconds int `json:"duration_seconds" binding:"min=0,max=2419200"` // Up to 28 days, 0 lifts the timeout
+}
+
+func TimeoutMember(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	targetUserID, err := parseTargetUserID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var payload TimeoutMemberPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := resolveModerationTarget(c, serverID, targetUserID)
+	if target == nil {
+		return
+	}
+
+	var until *time.Time
+	if payload.DurationSeconds > 0 {
+		t := time.Now().Add(time.Duration(payload.DurationSeconds) * time.Second)
+		until = &t
+	}
+
+	if err := database.DB.Model(&models.ServerMember{}).
+		Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, targetUserID).
+		Update("timeout_until", until).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timeout"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberTimeout, "member", idString(targetUserID), models.AuditChanges{
+		{Key: "timed_out", Old: target.TimedOut, New: until != nil},
+		{Key: "timeout_until", New: until},
+	})
+
+	// Timed out members can't stay in voice either
+	if until != nil {
+		webrtc.DisconnectFromServer(targetUserID, serverID)
+	}
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "SERVER_MEMBER_UPDATE",
+		Data: gin.H{
+			"user_id":       strconv.FormatUint(targetUserID, 10),
+			"timeout_until": until,
+		},
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":       strconv.FormatUint(targetUserID, 10),
+		"timeout_until": until,
+	})
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const maxPinsPerChannel = 50
+
+// Loads the message a pin route points at, responding with the error itself if it can't
+func resolvePinTarget(c *gin.Context) (uint64, models.Message, bool) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return 0, models.Message{}, false
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return 0, models.Message{}, false
+	}
+
+	var message models.Message
+	if err := database.DB.Where("id = ? AND channel_id = ?", messageID, channelID).First(&message).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return 0, models.Message{}, false
+	}
+
+	return serverID, message, true
+}
+
+// Tells the channel its pins changed. Clients refetch the list, last_pin_at lets them show a dot for new pins.
+func broadcastPinsUpdate(serverID uint64, channelID uint64) {
+	var lastPinAt *time.Time
+	var latest models.Message
+	if err := database.DB.Select("pinned_at").Where("channel_id = ? AND pinned_at IS NOT NULL", channelID).
+		Order("pinned_at desc").First(&latest).Error; err == nil {
+		lastPinAt = latest.PinnedAt
+	}
+
+	broadcastToChannel(serverID, channelID, "CHANNEL_PINS_UPDATE", gin.H{
+		"channel_id":  idString(channelID),
+		"last_pin_at": lastPinAt,
+	})
+}
+
+// ListPins returns a channel's pinned messages, most recently pinned first
+func ListPins(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	// The pin cap keeps this small enough to return in one go
+	messages := []models.Message{}
+	if err := withMessageRelations(database.DB).
+		Where("channel_id = ? AND pinned_at IS NOT NULL", channelID).
+		Order("pinned_at desc").Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pins"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := attachReactions(messages, userIDObj.(uint64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, messages)
+}
+
+func PinMessage(c *gin.Context) {
+	serverID, message, ok := resolvePinTarget(c)
+	if !ok {
+		return
+	}
+
+	// Pinning twice is a no-op
+	if message.PinnedAt != nil {
+		c.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	var pinned int64
+	database.DB.Model(&models.Message{}).Where("channel_id = ? AND pinned_at IS NOT NULL", message.ChannelID).Count(&pinned)
+	if pinned >= maxPinsPerChannel {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This channel already has the maximum of " + strconv.Itoa(maxPinsPerChannel) + " pinned messages"})
+		return
+	}
+
+	if err := database.DB.Model(&models.Message{}).Where("id = ?", message.ID).Update("pinned_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to pin message"})
+		return
+	}
+
+	// DMs have no audit log, any recipient can pin there
+	if serverID != 0 {
+		writeAuditLog(c, serverID, models.AuditMessagePin, "message", idString(message.ID), models.AuditChanges{
+			{Key: "author_id", New: optionalIDString(message.AuthorID)},
+			{Key: "channel_id", New: idString(message.ChannelID)},
+		})
+	}
+
+	withMessageRelations(database.DB).First(&message, message.ID)
+	broadcastToChannel(serverID, message.ChannelID, "MESSAGE_UPDATE", message)
+	broadcastPinsUpdate(serverID, message.ChannelID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func UnpinMessage(c *gin.Context) {
+	serverID, message, ok := resolvePinTarget(c)
+	if !ok {
+		return
+	}
+
+	if message.PinnedAt == nil {
+		c.JSON(http.StatusNoContent, nil)
+		return
+	}
+
+	if err := database.DB.Model(&models.Message{}).Where("id = ?", message.ID).Update("pinned_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unpin message"})
+		return
+	}
+
+	if serverID != 0 {
+		writeAuditLog(c, serverID, models.AuditMessageUnpin, "message", idString(message.ID), models.AuditChanges{
+			{Key: "author_id", Old: optionalIDString(message.AuthorID)},
+			{Key: "channel_id", Old: idString(message.ChannelID)},
+		})
+	}
+
+	withMessageRelations(database.DB).First(&message, message.ID)
+	broadcastToChannel(serverID, message.ChannelID, "MESSAGE_UPDATE", message)
+	broadcastPinsUpdate(serverID, message.ChannelID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
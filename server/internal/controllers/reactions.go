@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const (
+	maxReactionsPerMessage = 20 // Distinct emoji, not individual reactions
+	defaultReactorLimit    = 25
+	maxReactorLimit        = 100
+)
+
+var (
+	errInvalidEmoji  = errors.New("invalid emoji")
+	errReactionLimit = errors.New("too many distinct reactions")
+)
+
+// Turns the :emoji URL param into the key stored on a reaction.
+// Unicode emoji are used as is, custom emoji are sent as name:id and
+// must belong to the server the message lives in.
+func parseReactionEmoji(raw string, serverID uint64) (string, error) {
+	if raw == "" || len(raw) > 64 || !utf8.ValidString(raw) {
+		return "", errInvalidEmoji
+	}
+
+	if name, rawID, found := strings.Cut(raw, ":"); found {
+		emojiID, err := strconv.ParseUint(rawID, 10, 64)
+		if err != nil || serverID == 0 {
+			return "", errInvalidEmoji
+		}
+		var emoji models.Emoji
+		if err := database.DB.Where("id = ? AND server_id = ? AND name = ?", emojiID, serverID, name).First(&emoji).Error; err != nil {
+			return "", errInvalidEmoji
+		}
+		return idString(emoji.ID), nil
+	}
+
+	// Anything else has to at least look like an emoji rather than plain text
+	hasSymbol := false
+	for _, r := range raw {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return "", errInvalidEmoji
+		}
+		if r > unicode.MaxASCII {
+			hasSymbol = true
+		}
+	}
+	if !hasSymbol {
+		return "", errInvalidEmoji
+	}
+	return raw, nil
+}
+
+// Custom emoji are stored by ID, everything else is a Unicode emoji
+func isCustomEmojiKey(key string) bool {
+	_, err := strconv.ParseUint(key, 10, 64)
+	return err == nil
+}
+
+// Describes stored emoji keys the way clients expect them
+func describeEmoji(keys []string) map[string]models.ReactionEmoji {
+	described := make(map[string]models.ReactionEmoji, len(keys))
+
+	var customIDs []string
+	for _, key := range keys {
+		if isCustomEmojiKey(key) {
+			customIDs = append(customIDs, key)
+		} else {
+			described[key] = models.ReactionEmoji{Name: key}
+		}
+	}
+
+	if len(customIDs) > 0 {
+		var emojis []models.Emoji
+		database.DB.Where("id IN ?", customIDs).Find(&emojis)
+		for _, emoji := range emojis {
+			id := emoji.ID
+			described[idString(id)] = models.ReactionEmoji{ID: &id, Name: emoji.Name, ImageURL: emoji.ImageURL}
+		}
+	}
+
+	return described
+}
+
+// Fills in the reaction summary on a page of messages for the requesting user
+func attachReactions(messages []models.Message, userID uint64) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]uint64, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+
+	var rows []struct {
+		MessageID uint64
+		Emoji     string
+		Count     int
+		Me        int
+	}
+	// Reactions show up in the order they were first added
+	if err := database.DB.Model(&models.Reaction{}).
+		Select("message_id, emoji, COUNT(*) AS count, SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END) AS me", userID).
+		Where("message_id IN ?", ids).
+		Group("message_id, emoji").
+		Order("MIN(created_at) asc").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.Emoji)
+	}
+	described := describeEmoji(keys)
+
+	byMessage := make(map[uint64][]models.ReactionCount)
+	for _, row := range rows {
+		byMessage[row.MessageID] = append(byMessage[row.MessageID], models.ReactionCount{
+			Emoji: described[row.Emoji],
+			Count: row.Count,
+			Me:    row.Me > 0,
+		})
+	}
+	for i := range messages {
+		messages[i].Reactions = byMessage[messages[i].ID]
+	}
+	return nil
+}
+
+// Shared lookups for the reaction endpoints. Writes the error response itself.
+func resolveReactionTarget(c *gin.Context) (uint64, models.Message, string, bool) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return 0, models.Message{}, "", false
+	}
+
+	var message models.Message
+	if err := database.DB.Where("id = ? AND channel_id = ?", c.Param("messageID"), channelID).First(&message).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return 0, models.Message{}, "", false
+	}
+
+	emoji, err := parseReactionEmoji(c.Param("emoji"), serverID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown emoji"})
+		return 0, models.Message{}, "", false
+	}
+
+	return serverID, message, emoji, true
+}
+
+// Announces a reaction change to everyone who can see the message
+func broadcastReaction(serverID uint64, message models.Message, event string, userID uint64, emoji string) {
+	broadcastToChannel(serverID, message.ChannelID, event, gin.H{
+		"message_id": idString(message.ID),
+		"channel_id": idString(message.ChannelID),
+		"user_id":    idString(userID),
+		"emoji":      describeEmoji([]string{emoji})[emoji],
+	})
+}
+
+func ListReactionUsers(c *gin.Context) {
+	_, message, emoji, ok := resolveReactionTarget(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultReactorLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxReactorLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxReactorLimit)})
+			return
+		}
+	}
+
+	// Paged by user ID so the list stays stable while people keep reacting
+	after, _, err := parseCursor(c, "after")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID format"})
+		return
+	}
+
+	var users []models.User
+	if err := database.DB.
+		Where("id IN (?)", database.DB.Model(&models.Reaction{}).Select("user_id").Where("message_id = ? AND emoji = ?", message.ID, emoji)).
+		Where("id > ?", after).
+		Order("id asc").
+		Limit(limit).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func AddReaction(c *gin.Context) {
+	serverID, message, emoji, ok := resolveReactionTarget(c)
+	if !ok {
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	reaction := models.Reaction{MessageID: message.ID, UserID: userID, Emoji: emoji}
+	var added bool
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locking the message makes racing reactions take turns, so the cap can't be overshot
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Message{}, message.ID).Error; err != nil {
+			return err
+		}
+
+		// A new emoji on the message has to fit under the cap, piling onto an existing one is always fine
+		var existing int64
+		if err := tx.Model(&models.Reaction{}).Where("message_id = ? AND emoji = ?", message.ID, emoji).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing == 0 {
+			var distinct int64
+			if err := tx.Model(&models.Reaction{}).Where("message_id = ?", message.ID).Distinct("emoji").Count(&distinct).Error; err != nil {
+				return err
+			}
+			if distinct >= maxReactionsPerMessage {
+				return errReactionLimit
+			}
+		}
+
+		// FirstOrCreate keeps this idempotent if they already reacted
+		result := tx.Where(&reaction).FirstOrCreate(&reaction)
+		added = result.RowsAffected > 0
+		return result.Error
+	})
+	if err != nil {
+		if errors.Is(err, errReactionLimit) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "This message already has the maximum number of reactions"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add reaction"})
+		return
+	}
+
+	if added {
+		broadcastReaction(serverID, message, "MESSAGE_REACTION_ADD", userID, emoji)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func RemoveReaction(c *gin.Context) {
+	serverID, message, emoji, ok := resolveReactionTarget(c)
+	if !ok {
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// "@me" removes your own reaction, anyone else's needs manage messages
+	targetUserID := userID
+	if raw := c.Param("userID"); raw != "@me" {
+		var err error
+		targetUserID, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+	}
+	if targetUserID != userID && !currentChannelPermissions(c).Has(models.PermissionManageMessages) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to remove other reactions"})
+		return
+	}
+
+	result := database.DB.Where("message_id = ? AND user_id = ? AND emoji = ?", message.ID, targetUserID, emoji).
+		Delete(&models.Reaction{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove reaction"})
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		broadcastReaction(serverID, message, "MESSAGE_REACTION_REMOVE", targetUserID, emoji)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
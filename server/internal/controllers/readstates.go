@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/readstates"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+// Fills in the requesting user's read state on each channel. Voice channels, categories and forums have no history of their own.
+func attachReadStates(channels []models.Channel, userID uint64) error {
+	var channelIDs []uint64
+	for _, channel := range channels {
+		if channel.Type != models.ChannelTypeVoice && !channel.IsCategory() && !channel.IsForum() {
+			channelIDs = append(channelIDs, channel.ID)
+		}
+	}
+
+	states, err := readstates.ForChannels(userID, channelIDs)
+	if err != nil {
+		return err
+	}
+	for i := range channels {
+		channels[i].ReadState = states[channels[i].ID]
+	}
+	return nil
+}
+
+// AckMessage moves the user's read marker to a message. Acking an older message marks the rest unread.
+func AckMessage(c *gin.Context) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	messageID, err := strconv.ParseUint(c.Param("messageID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message ID format"})
+		return
+	}
+
+	var count int64
+	database.DB.Model(&models.Message{}).Where("id = ? AND channel_id = ?", messageID, channelID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	state, err := readstates.Ack(userID, channelID, messageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update read state"})
+		return
+	}
+
+	// Keep the user's other sessions in sync
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID:  serverID,
+		TargetChannelID: channelID,
+		Event:           "MESSAGE_ACK",
+		Data:            state,
+		TargetUserIDs:   []uint64{userID},
+	}
+
+	c.JSON(http.StatusOK, state)
+}
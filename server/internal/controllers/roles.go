@@ -0,0 +1,389 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
+)
+
+// Helper to load the role in the URL, scoped to the server in the URL
+func findRole(c *gin.Context, serverID uint64) (models.Role, error) {
+	var role models.Role
+	roleID, err := strconv.ParseUint(c.Param("roleID"), 10, 64)
+	if err != nil {
+		return role, err
+	}
+	err = database.DB.Where("id = ? AND server_id = ?", roleID, serverID).First(&role).Error
+	return role, err
+}
+
+// Tells the server that a member's role list changed
+func broadcastMemberRoles(serverID uint64, userID uint64) {
+	var roles []models.MemberRole
+	database.DB.Where("server_id = ? AND user_id = ?", serverID, userID).Find(&roles)
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "SERVER_MEMBER_UPDATE",
+		Data: gin.H{
+			"user_id": strconv.FormatUint(userID, 10),
+			"roles":   roles,
+		},
+	}
+}
+
+func ListRoles(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	// Highest roles first, matching how clients render the hierarchy
+	var roles []models.Role
+	if err := database.DB.Where("server_id = ?", serverID).
+		Order("position desc, id asc").
+		Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+type CreateRolePayload struct {
+	Name        string             `json:"name" binding:"required,min=1,max=100"`
+	Color       int                `json:"color" binding:"omitempty,min=0,max=16777215"`
+	Hoist       bool               `json:"hoist"`
+	Permissions *models.Permission `json:"permissions,string"`
+}
+
+func CreateRole(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var payload CreateRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member := currentMember(c)
+
+	var perms models.Permission
+	if payload.Permissions != nil {
+		perms = *payload.Permissions
+	}
+
+	// Nobody can hand out permissions they don't have themselves
+	if !member.Has(perms) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have"})
+		return
+	}
+
+	role := models.Role{
+		ID:          utils.GenerateID(),
+		ServerID:    serverID,
+		Name:        payload.Name,
+		Color:       payload.Color,
+		Position:    1, // Sits right above @everyone
+		Hoist:       payload.Hoist,
+		Permissions: perms,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Make room at the bottom of the hierarchy for the new role
+		if err := tx.Model(&models.Role{}).
+			Where("server_id = ? AND is_default = ?", serverID, false).
+			Update("position", gorm.Expr("position + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Create(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditRoleCreate, "role", idString(role.ID), models.AuditChanges{
+		{Key: "name", New: role.Name},
+		{Key: "color", New: role.Color},
+		{Key: "hoist", New: role.Hoist},
+		{Key: "permissions", New: role.Permissions},
+	})
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "ROLE_CREATE",
+		Data:           role,
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+type UpdateRolePayload struct {
+	Name        *string            `json:"name" binding:"omitempty,min=1,max=100"`
+	Color       *int               `json:"color" binding:"omitempty,min=0,max=16777215"`
+	Position    *int               `json:"position" binding:"omitempty,min=1"`
+	Hoist       *bool              `json:"hoist"`
+	Permissions *models.Permission `json:"permissions,string"`
+}
+
+func UpdateRole(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	var payload UpdateRolePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	role, err := findRole(c, serverID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	member := currentMember(c)
+
+	// Roles at or above your own top role are out of reach
+	if !role.IsDefault && !member.CanManageRole(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage a role at or above your highest role"})
+		return
+	}
+
+	// The @everyone role only has its permissions edited
+	if role.IsDefault && (payload.Name != nil || payload.Position != nil || payload.Hoist != nil || payload.Color != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only the permissions of the @everyone role can be changed"})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Name != nil {
+		updates["name"] = *payload.Name
+	}
+	if payload.Color != nil {
+		updates["color"] = *payload.Color
+	}
+	if payload.Hoist != nil {
+		updates["hoist"] = *payload.Hoist
+	}
+	if payload.Position != nil {
+		// Can't lift a role to or above your own rank
+		if *payload.Position >= member.HighestPosition() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot move a role to or above your highest role"})
+			return
+		}
+		// Past the top role would leave a gap, it just becomes the top role
+		var top int
+		database.DB.Model(&models.Role{}).Where("server_id = ? AND is_default = ?", serverID, false).
+			Select("COALESCE(MAX(position), 0)").Scan(&top)
+		updates["position"] = min(*payload.Position, top)
+	}
+	if payload.Permissions != nil {
+		if !member.Has(*payload.Permissions) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot grant permissions you do not have"})
+			return
+		}
+		updates["permissions"] = *payload.Permissions
+	}
+
+	// Snapshot the current values so the audit log can show what changed
+	changes := diffUpdates(map[string]interface{}{
+		"name":        role.Name,
+		"color":       role.Color,
+		"hoist":       role.Hoist,
+		"position":    role.Position,
+		"permissions": role.Permissions,
+	}, updates)
+
+	// Only hit the database if there's actually something to update
+	if len(updates) > 0 {
+		err = database.DB.Transaction(func(tx *gorm.DB) error {
+			// Slide the roles in between over by one so no two roles share a position
+			if position, ok := updates["position"].(int); ok && position != role.Position {
+				others := tx.Model(&models.Role{}).Where("server_id = ? AND is_default = ? AND id <> ?", serverID, false, role.ID)
+				var shift *gorm.DB
+				if position < role.Position {
+					shift = others.Where("position >= ? AND position < ?", position, role.Position).Update("position", gorm.Expr("position + 1"))
+				} else {
+					shift = others.Where("position > ? AND position <= ?", role.Position, position).Update("position", gorm.Expr("position - 1"))
+				}
+				if shift.Error != nil {
+					return shift.Error
+				}
+			}
+			return tx.Model(&role).Updates(updates).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+			return
+		}
+	}
+
+	if len(changes) > 0 {
+		writeAuditLog(c, serverID, models.AuditRoleUpdate, "role", idString(role.ID), changes)
+	}
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "ROLE_UPDATE",
+		Data:           role,
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+func DeleteRole(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	role, err := findRole(c, serverID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	if role.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The @everyone role cannot be deleted"})
+		return
+	}
+
+	if !currentMember(c).CanManageRole(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage a role at or above your highest role"})
+		return
+	}
+
+	// Remove assignments alongside the role so nobody keeps a dangling grant
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.MemberRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.MessageRoleMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditRoleDelete, "role", idString(role.ID), models.AuditChanges{
+		{Key: "name", Old: role.Name},
+		{Key: "permissions", Old: role.Permissions},
+	})
+
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "ROLE_DELETE",
+		Data:           gin.H{"id": strconv.FormatUint(role.ID, 10)},
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// Shared checks for assigning and removing a role from a member
+func resolveRoleAssignment(c *gin.Context) (uint64, uint64, models.Role, bool) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return 0, 0, models.Role{}, false
+	}
+
+	targetUserID, err := strconv.ParseUint(c.Param("userID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, 0, models.Role{}, false
+	}
+
+	role, err := findRole(c, serverID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return 0, 0, models.Role{}, false
+	}
+
+	// @everyone is implied, it is never assigned directly
+	if role.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The @everyone role cannot be assigned"})
+		return 0, 0, models.Role{}, false
+	}
+
+	if !currentMember(c).CanManageRole(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot manage a role at or above your highest role"})
+		return 0, 0, models.Role{}, false
+	}
+
+	if _, err := permissions.Resolve(serverID, targetUserID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return 0, 0, models.Role{}, false
+	}
+
+	return serverID, targetUserID, role, true
+}
+
+func AddMemberRole(c *gin.Context) {
+	serverID, targetUserID, role, ok := resolveRoleAssignment(c)
+	if !ok {
+		return
+	}
+
+	assignment := models.MemberRole{
+		ServerID: serverID,
+		UserID:   targetUserID,
+		RoleID:   role.ID,
+	}
+
+	// FirstOrCreate keeps this idempotent if the role is already held
+	if err := database.DB.Where(&assignment).FirstOrCreate(&assignment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign role"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberRoleAdd, "member", idString(targetUserID), models.AuditChanges{
+		{Key: "role_id", New: idString(role.ID)},
+	})
+
+	broadcastMemberRoles(serverID, targetUserID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func RemoveMemberRole(c *gin.Context) {
+	serverID, targetUserID, role, ok := resolveRoleAssignment(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Where("server_id = ? AND user_id = ? AND role_id = ?", serverID, targetUserID, role.ID).
+		Delete(&models.MemberRole{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditMemberRoleRemove, "member", idString(targetUserID), models.AuditChanges{
+		{Key: "role_id", Old: idString(role.ID)},
+	})
+
+	broadcastMemberRoles(serverID, targetUserID)
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/ratelimit"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+const (
+	maxSchedulesPerUser  = 50
+	maxScheduleAhead     = 365 * 24 * time.Hour
+	minRecurrenceGap     = 10 * time.Minute // Stops a schedule from turning into a spam bot
+	schedulerInterval    = 15 * time.Second
+	schedulerBatchSize   = 100
+	recurrenceGapSamples = 10 // Upcoming runs checked against the minimum gap
+)
+
+var errScheduleClaimed = errors.New("schedule already dispatched")
+
+// Parses a cron expression in the schedule's timezone.
+// The timezone has its own field, so CRON_TZ prefixes aren't accepted in the expression.
+func parseRecurrence(expr string, timezone string) (cron.Schedule, *time.Location, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, errors.New("Unknown timezone")
+	}
+	if strings.Contains(expr, "TZ=") {
+		return nil, nil, errors.New("Set the timezone field instead of CRON_TZ")
+	}
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, nil, errors.New("Invalid recurrence, use a 5 field cron expression like \"0 9 * * 1-5\"")
+	}
+
+	from := time.Now().In(loc)
+	for i := 0; i < recurrenceGapSamples; i++ {
+		next := schedule.Next(from)
+		if next.IsZero() {
+			return nil, nil, errors.New("Recurrence never fires")
+		}
+		if i > 0 && next.Sub(from) < minRecurrenceGap {
+			return nil, nil, errors.New("Recurring messages must be at least 10 minutes apart")
+		}
+		from = next
+	}
+	return schedule, loc, nil
+}
+
+// Works out when a schedule fires next after the given time. One-offs have nothing after their run.
+func nextScheduledRun(schedule models.ScheduledMessage, after time.Time) (time.Time, bool) {
+	if schedule.Recurrence == "" {
+		return time.Time{}, false
+	}
+	parsed, loc, err := parseRecurrence(schedule.Recurrence, schedule.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed.Next(after.In(loc)).UTC(), true
+}
+
+type ScheduledMessagePayload struct {
+	Content    *string    `json:"content" binding:"omitempty,min=1,max=2000"`
+	SendAt     *time.Time `json:"send_at"`                                   // One-off
+	Recurrence *string    `json:"recurrence" binding:"omitempty,max=100"`    // Repeating
+	Timezone   *string    `json:"timezone" binding:"omitempty,min=1,max=64"` // Defaults to UTC
+}
+
+// Applies the timing half of a payload and recalculates the next run.
+// Setting send_at turns a schedule into a one-off, setting recurrence makes it repeat.
+func applyScheduleTiming(schedule *models.ScheduledMessage, payload ScheduledMessagePayload) error {
+	if payload.SendAt != nil && payload.Recurrence != nil {
+		return errors.New("Send either send_at or recurrence, not both")
+	}
+	if payload.Timezone != nil {
+		schedule.Timezone = *payload.Timezone
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+
+	now := time.Now()
+	switch {
+	case payload.SendAt != nil:
+		if !payload.SendAt.After(now) {
+			return errors.New("send_at must be in the future")
+		}
+		if payload.SendAt.Sub(now) > maxScheduleAhead {
+			return errors.New("Messages can be scheduled at most a year ahead")
+		}
+		schedule.Recurrence = ""
+		schedule.NextRunAt = payload.SendAt.UTC()
+		return nil
+
+	case payload.Recurrence != nil:
+		schedule.Recurrence = strings.TrimSpace(*payload.Recurrence)
+		if schedule.Recurrence == "" {
+			return errors.New("recurrence can't be empty")
+		}
+	}
+
+	// Also reached when only the timezone changed
+	if schedule.Recurrence != "" {
+		parsed, loc, err := parseRecurrence(schedule.Recurrence, schedule.Timezone)
+		if err != nil {
+			return err
+		}
+		schedule.NextRunAt = parsed.Next(now.In(loc)).UTC()
+	} else if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+		return errors.New("Unknown timezone")
+	}
+	return nil
+}
+
+// Loads one of the caller's schedules in the channel from the URL
+func findOwnSchedule(c *gin.Context, channelID uint64) (models.ScheduledMessage, bool) {
+	var schedule models.ScheduledMessage
+	scheduleID, err := strconv.ParseUint(c.Param("scheduleID"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID format"})
+		return schedule, false
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := database.DB.Where("id = ? AND channel_id = ? AND author_id = ?", scheduleID, channelID, userIDObj.(uint64)).
+		First(&schedule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled message not found"})
+		return schedule, false
+	}
+	return schedule, true
+}
+
+// ListScheduledMessages returns the caller's schedules in a channel, soonest first
+func ListScheduledMessages(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	schedules := []models.ScheduledMessage{}
+	if err := database.DB.Where("channel_id = ? AND author_id = ?", channelID, userIDObj.(uint64)).
+		Order("next_run_at asc").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// ListMyScheduledMessages returns every schedule the caller has, across all channels
+func ListMyScheduledMessages(c *gin.Context) {
+	userIDObj, _ := c.Get("user_id")
+	schedules := []models.ScheduledMessage{}
+	if err := database.DB.Where("author_id = ?", userIDObj.(uint64)).
+		Order("next_run_at asc").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scheduled messages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+func CreateScheduledMessage(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	var payload ScheduledMessagePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if payload.Content == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A scheduled message needs content"})
+		return
+	}
+	if payload.SendAt == nil && payload.Recurrence == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide send_at for a one-off or recurrence for a repeating message"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var count int64
+	database.DB.Model(&models.ScheduledMessage{}).Where("author_id = ?", userID).Count(&count)
+	if count >= maxSchedulesPerUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You can have at most " + strconv.Itoa(maxSchedulesPerUser) + " scheduled messages"})
+		return
+	}
+
+	schedule := models.ScheduledMessage{
+		ID:        utils.GenerateID(),
+		ChannelID: channelID,
+		AuthorID:  userID,
+		Content:   *payload.Content,
+	}
+	if err := applyScheduleTiming(&schedule, payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule message"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+func UpdateScheduledMessage(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	var payload ScheduledMessagePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, ok := findOwnSchedule(c, channelID)
+	if !ok {
+		return
+	}
+
+	if payload.Content != nil {
+		schedule.Content = *payload.Content
+	}
+	if err := applyScheduleTiming(&schedule, payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Model(&models.ScheduledMessage{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{
+		"content":     schedule.Content,
+		"recurrence":  schedule.Recurrence,
+		"timezone":    schedule.Timezone,
+		"next_run_at": schedule.NextRunAt,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scheduled message"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func DeleteScheduledMessage(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	schedule, ok := findOwnSchedule(c, channelID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scheduled message"})
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+// RunScheduler sends scheduled messages once they're due.
+// This runs in its own background goroutine (started in main.go).
+func RunScheduler() {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		dispatchDueMessages()
+		<-ticker.C
+	}
+}
+
+func dispatchDueMessages() {
+	var due []models.ScheduledMessage
+	if err := database.DB.Where("next_run_at <= ?", time.Now().UTC()).
+		Order("next_run_at asc").Limit(schedulerBatchSize).Find(&due).Error; err != nil {
+		log.Printf("Failed to load scheduled messages: %v", err)
+		return
+	}
+
+	for _, schedule := range due {
+		dispatchScheduledMessage(schedule)
+	}
+}
+
+// Pushes a due run back by wait. Recurring schedules keep their cadence, only this run moves.
+func deferScheduledMessage(schedule models.ScheduledMessage, now time.Time, wait time.Duration) {
+	if err := database.DB.Model(&models.ScheduledMessage{}).
+		Where("id = ? AND next_run_at <= ?", schedule.ID, now).
+		Update("next_run_at", now.Add(wait)).Error; err != nil {
+		log.Printf("Failed to defer scheduled message %d: %v", schedule.ID, err)
+	}
+}
+
+// Sends one due schedule. Claiming the run and saving the message happen in the same
+// transaction, so a crash either does both or neither and a restart never sends twice.
+func dispatchScheduledMessage(schedule models.ScheduledMessage) {
+	now := time.Now().UTC()
+
+	// Runs missed while the server was down collapse into this one
+	next, recurring := nextScheduledRun(schedule, now)
+
+	// The author has to still be allowed to talk there, checked now rather than when it was scheduled
+	var channel models.Channel
+	var serverID uint64
+	var perms models.Permission
+	allowed := database.DB.First(&channel, schedule.ChannelID).Error == nil
+	if allowed && channel.ServerID != nil {
+		serverID = *channel.ServerID
+		allowed = false
+		if member, err := permissions.Resolve(serverID, schedule.AuthorID); err == nil {
+			if perms, err = member.ForChannel(channel.ID); err == nil {
+				allowed = perms.Has(models.PermissionViewChannels) && perms.Has(models.PermissionSendMessages) &&
+					(!channel.Locked || perms.Has(models.PermissionManageChannels))
+			}
+		}
+	} else if allowed {
+		allowed = permissions.IsDMRecipient(channel.ID, schedule.AuthorID)
+	}
+
+	// Scheduled messages go through the same limits as ones sent by hand. When one holds
+	// the run back it's tried again once the limit lifts instead of being dropped.
+	slowModeKey := ""
+	if allowed {
+		if ok, retryAfter := ratelimit.Messages.Allow(strconv.FormatUint(schedule.AuthorID, 10)); !ok {
+			deferScheduledMessage(schedule, now, retryAfter)
+			return
+		}
+		if channel.SlowModeSeconds > 0 && !perms.Has(models.PermissionBypassSlowMode) {
+			slowModeKey = strconv.FormatUint(channel.ID, 10) + ":" + strconv.FormatUint(schedule.AuthorID, 10)
+			if ok, retryAfter := ratelimit.SlowMode.Take(slowModeKey, time.Duration(channel.SlowModeSeconds)*time.Second); !ok {
+				deferScheduledMessage(schedule, now, retryAfter)
+				return
+			}
+		}
+	}
+
+	message := models.Message{
+		ID:        utils.GenerateID(),
+		ChannelID: schedule.ChannelID,
+		AuthorID:  &schedule.AuthorID,
+		Content:   schedule.Content,
+	}
+	mentions := resolveMentions(serverID, schedule.ChannelID, schedule.Content, perms)
+	applyMentions(&message, mentions)
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Only one dispatcher gets to move a due schedule on, anyone else finds it no longer due
+		claim := tx.Where("id = ? AND next_run_at <= ?", schedule.ID, now)
+		var result *gorm.DB
+		if recurring {
+			result = claim.Model(&models.ScheduledMessage{}).Updates(map[string]interface{}{"next_run_at": next, "last_run_at": now})
+		} else {
+			result = claim.Delete(&models.ScheduledMessage{})
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errScheduleClaimed
+		}
+
+		if !allowed {
+			return nil
+		}
+		return tx.Create(&message).Error
+	})
+	if errors.Is(err, errScheduleClaimed) {
+		ratelimit.SlowMode.Clear(slowModeKey)
+		return
+	}
+	if err != nil {
+		ratelimit.SlowMode.Clear(slowModeKey)
+		log.Printf("Failed to send scheduled message %d: %v", schedule.ID, err)
+		return
+	}
+	if !allowed {
+		log.Printf("Skipped scheduled message %d, its author can no longer send in channel %d", schedule.ID, schedule.ChannelID)
+		return
+	}
+
+	publishMessage(serverID, channel, &message, mentions)
+}
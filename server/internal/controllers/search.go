@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const (
+	defaultSearchLimit = 25
+	maxSearchLimit     = 50
+	maxSearchOffset    = 5000
+	maxSearchQuery     = 500
+	searchContextSize  = 2 // Messages shown on either side of a hit
+)
+
+// SearchResult is one matching message with a little of the conversation around it
+type SearchResult struct {
+	Message       models.Message   `json:"message"`
+	ContextBefore []models.Message `json:"context_before"`
+	ContextAfter  []models.Message `json:"context_after"`
+}
+
+type SearchResults struct {
+	TotalResults int64          `json:"total_results"`
+	Results      []SearchResult `json:"results"`
+}
+
+// Reads a date filter, either a full RFC 3339 timestamp or just a day
+func parseSearchTime(c *gin.Context, key string) (time.Time, bool, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse("2006-01-02", raw)
+	return t, true, err
+}
+
+func SearchMessages(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if len(text) > maxSearchQuery {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query can be at most " + strconv.Itoa(maxSearchQuery) + " characters"})
+		return
+	}
+
+	authorID, hasAuthor, errAuthor := parseCursor(c, "author_id")
+	channelID, hasChannel, errChannel := parseCursor(c, "channel_id")
+	mentionsID, hasMentions, errMentions := parseCursor(c, "mentions")
+	if errAuthor != nil || errChannel != nil || errMentions != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	before, hasBefore, errBefore := parseSearchTime(c, "before")
+	after, hasAfter, errAfter := parseSearchTime(c, "after")
+	if errBefore != nil || errAfter != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dates must be YYYY-MM-DD or RFC 3339"})
+		return
+	}
+
+	hasAttachment := c.Query("has_attachment")
+	if hasAttachment != "" && hasAttachment != "true" && hasAttachment != "false" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "has_attachment must be true or false"})
+		return
+	}
+
+	if text == "" && !hasAuthor && !hasChannel && !hasMentions && !hasBefore && !hasAfter && hasAttachment == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a search query or at least one filter"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxSearchLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limit must be between 1 and " + strconv.Itoa(maxSearchLimit)})
+			return
+		}
+	}
+	offset := 0
+	if raw := c.Query("offset"); raw != "" {
+		var err error
+		offset, err = strconv.Atoi(raw)
+		if err != nil || offset < 0 || offset > maxSearchOffset {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Offset must be between 0 and " + strconv.Itoa(maxSearchOffset)})
+			return
+		}
+	}
+
+	// Newest first unless asked otherwise, snowflakes sort by send time
+	order := "messages.id desc"
+	switch c.Query("sort") {
+	case "", "newest":
+	case "oldest":
+		order = "messages.id asc"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be newest or oldest"})
+		return
+	}
+
+	// Only ever look in channels this member can see
+	channelIDs, err := currentMember(c).VisibleChannelIDs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
+		return
+	}
+	if hasChannel {
+		allowed := false
+		for _, id := range channelIDs {
+			if id == channelID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+			return
+		}
+		channelIDs = []uint64{channelID}
+	}
+
+	results := SearchResults{Results: []SearchResult{}}
+	if len(channelIDs) == 0 {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+
+	query := database.DB.Model(&models.Message{}).Where("messages.channel_id IN ?", channelIDs)
+	if text != "" {
+		query = database.MatchMessages(query, text)
+	}
+	if hasAuthor {
+		query = query.Where("messages.author_id = ?", authorID)
+	}
+	if hasMentions {
+		query = query.Where("EXISTS (SELECT 1 FROM message_mentions WHERE message_mentions.message_id = messages.id AND message_mentions.user_id = ?)", mentionsID)
+	}
+	if hasBefore {
+		query = query.Where("messages.created_at < ?", before)
+	}
+	if hasAfter {
+		query = query.Where("messages.created_at > ?", after)
+	}
+	switch hasAttachment {
+	case "true":
+		query = query.Where("EXISTS (SELECT 1 FROM attachments WHERE attachments.message_id = messages.id)")
+	case "false":
+		query = query.Where("NOT EXISTS (SELECT 1 FROM attachments WHERE attachments.message_id = messages.id)")
+	}
+
+	if err := query.Count(&results.TotalResults).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
+		return
+	}
+
+	var hits []models.Message
+	if err := withMessageRelations(query).Order(order).Limit(limit).Offset(offset).Find(&hits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search messages"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := attachReactions(hits, userIDObj.(uint64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reactions"})
+		return
+	}
+
+	for _, hit := range hits {
+		result := SearchResult{Message: hit}
+		result.ContextBefore, _, err = fetchMessageSlice(hit.ChannelID, "id < ?", hit.ID, "id desc", searchContextSize)
+		if err == nil {
+			result.ContextAfter, _, err = fetchMessageSlice(hit.ChannelID, "id > ?", hit.ID, "id asc", searchContextSize)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch message context"})
+			return
+		}
+		reverseMessages(result.ContextBefore)
+		results.Results = append(results.Results, result)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
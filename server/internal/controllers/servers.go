@@ -1,14 +1,18 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 
 	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
 	"github.com/jonahgcarpenter/hermes/server/internal/utils"
 	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
 )
@@ -19,13 +23,21 @@ func parseServerID(c *gin.Context) (uint64, error) {
 	return strconv.ParseUint(idStr, 10, 64)
 }
 
+// Helper to grab the member resolved by RequireMembership or RequirePermission
+func currentMember(c *gin.Context) *permissions.Member {
+	memberObj, _ := c.Get("member")
+	return memberObj.(*permissions.Member)
+}
+
 func ListServers(c *gin.Context) {
 	userID, _ := c.Get("user_id")
 
 	var memberships []models.ServerMember
-	// Fetch active memberships and preload the Server data
+	// Fetch active memberships and preload the Server data.
+	// The subquery skips servers that have been soft deleted.
 	if err := database.DB.Preload("Server").
 		Where("user_id = ? AND left_at IS NULL", userID).
+		Where("server_id IN (?)", database.DB.Model(&models.Server{}).Select("id")).
 		Find(&memberships).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch servers"})
 		return
@@ -53,7 +65,7 @@ func ListServerMembers(c *gin.Context) {
 
 	// We use Preload("User") to include the actual profile data for each member
 	// We filter by left_at IS NULL to ensure we only get active participants
-	result := database.DB.Preload("User").
+	result := database.DB.Preload("User").Preload("Roles").
 		Where("server_id = ? AND left_at IS NULL", serverID).
 		Find(&members)
 
@@ -62,12 +74,20 @@ func ListServerMembers(c *gin.Context) {
 		return
 	}
 
+	// Flag the owner so clients don't need a second request to find them
+	var server models.Server
+	database.DB.Select("owner_id").First(&server, serverID)
+	for i := range members {
+		members[i].IsOwner = members[i].UserID == server.OwnerID
+	}
+
 	c.JSON(http.StatusOK, members)
 }
 
+// Sent as JSON, or as multipart with an "icon" file field
 type CreateServerPayload struct {
-	Name    string `json:"name" binding:"required,min=2,max=100"`
-	IconURL string `json:"icon_url" binding:"omitempty,url"`
+	Name    string  `json:"name" form:"name" binding:"required,min=2,max=100"`
+	IconURL *string `json:"icon_url" form:"icon_url"` // No longer accepted, only here to reject it
 }
 
 func CreateServer(c *gin.Context) {
@@ -75,11 +95,30 @@ func CreateServer(c *gin.Context) {
 	userIDObj, _ := c.Get("user_id")
 	userID := userIDObj.(uint64)
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadSize+1<<20)
+
 	var payload CreateServerPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBind(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if payload.IconURL != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "icon_url can no longer be set, upload the picture in the icon field instead"})
+		return
+	}
+
+	icon, err := decodeUploadedImage(c, "icon")
+	if err != nil {
+		imageErrorResponse(c, err)
+		return
+	}
+	var iconURL string
+	if icon != nil {
+		if iconURL, err = storeProfileImage(icon); err != nil {
+			imageErrorResponse(c, err)
+			return
+		}
+	}
 
 	// Generate Snowflake ID
 	serverID := utils.GenerateID()
@@ -87,7 +126,7 @@ func CreateServer(c *gin.Context) {
 	server := models.Server{
 		ID:      serverID,
 		Name:    payload.Name,
-		IconURL: payload.IconURL,
+		IconURL: iconURL,
 		OwnerID: userID,
 	}
 
@@ -104,7 +143,6 @@ func CreateServer(c *gin.Context) {
 	member := models.ServerMember{
 		ServerID: server.ID,
 		UserID:   userID,
-		Role:     "owner",
 	}
 
 	if err := tx.Create(&member).Error; err != nil {
@@ -113,10 +151,26 @@ func CreateServer(c *gin.Context) {
 		return
 	}
 
+	// Create the @everyone role every member implicitly holds
+	everyoneRole := models.Role{
+		ID:          utils.GenerateID(),
+		ServerID:    server.ID,
+		Name:        "@everyone",
+		Position:    0,
+		Permissions: models.DefaultPermissions,
+		IsDefault:   true,
+	}
+
+	if err := tx.Create(&everyoneRole).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create default role"})
+		return
+	}
+
 	// Create default "general" text channel
 	generalChannel := models.Channel{
 		ID:       utils.GenerateID(),
-		ServerID: server.ID,
+		ServerID: &server.ID,
 		Name:     "general",
 		Type:     models.ChannelTypeText,
 		Position: 0, // Appears first
@@ -131,7 +185,7 @@ func CreateServer(c *gin.Context) {
 	// Create default "voice" channel
 	voiceChannel := models.Channel{
 		ID:       utils.GenerateID(),
-		ServerID: server.ID,
+		ServerID: &server.ID,
 		Name:     "voice",
 		Type:     models.ChannelTypeVoice,
 		Position: 1, // Appears underneath the text channel
@@ -170,9 +224,13 @@ func ServerDetails(c *gin.Context) {
 	c.JSON(http.StatusOK, server)
 }
 
+// Sent as JSON, or as multipart with the new icon in an "icon" file field
 type UpdateServerPayload struct {
-	Name    *string `json:"name" binding:"omitempty,min=2,max=100"`
-	IconURL *string `json:"icon_url" binding:"omitempty,url"`
+	Name                  *string `json:"name" form:"name" binding:"omitempty,min=2,max=100"`
+	RemoveIcon            bool    `json:"remove_icon" form:"remove_icon"`
+	AllowDirectJoin       *bool   `json:"allow_direct_join" form:"allow_direct_join"`
+	RevisionRetentionDays *int    `json:"revision_retention_days" form:"revision_retention_days" binding:"omitempty,min=0,max=3650"`
+	IconURL               *string `json:"icon_url" form:"icon_url"` // No longer accepted, only here to reject it
 }
 
 func UpdateServer(c *gin.Context) {
@@ -182,11 +240,23 @@ func UpdateServer(c *gin.Context) {
 		return
 	}
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadSize+1<<20)
+
 	var payload UpdateServerPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBind(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if payload.IconURL != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "icon_url can no longer be set, upload the picture in the icon field instead"})
+		return
+	}
+
+	icon, err := decodeUploadedImage(c, "icon")
+	if err != nil {
+		imageErrorResponse(c, err)
+		return
+	}
 
 	var server models.Server
 	if err := database.DB.First(&server, serverID).Error; err != nil {
@@ -199,9 +269,30 @@ func UpdateServer(c *gin.Context) {
 	if payload.Name != nil {
 		updates["name"] = *payload.Name
 	}
-	if payload.IconURL != nil {
-		updates["icon_url"] = *payload.IconURL
+	if icon != nil {
+		iconURL, err := storeProfileImage(icon)
+		if err != nil {
+			imageErrorResponse(c, err)
+			return
+		}
+		updates["icon_url"] = iconURL
+	} else if payload.RemoveIcon {
+		updates["icon_url"] = ""
+	}
+	if payload.AllowDirectJoin != nil {
+		updates["allow_direct_join"] = *payload.AllowDirectJoin
 	}
+	if payload.RevisionRetentionDays != nil {
+		updates["revision_retention_days"] = *payload.RevisionRetentionDays
+	}
+
+	// Snapshot the current values so the audit log can show what changed
+	changes := diffUpdates(map[string]interface{}{
+		"name":                    server.Name,
+		"icon_url":                server.IconURL,
+		"allow_direct_join":       server.AllowDirectJoin,
+		"revision_retention_days": server.RevisionRetentionDays,
+	}, updates)
 
 	// Only hit the database if there's actually something to update
 	if len(updates) > 0 {
@@ -211,6 +302,10 @@ func UpdateServer(c *gin.Context) {
 		}
 	}
 
+	if len(changes) > 0 {
+		writeAuditLog(c, serverID, models.AuditServerUpdate, "server", idString(serverID), changes)
+	}
+
 	c.JSON(http.StatusOK, server)
 }
 
@@ -227,52 +322,137 @@ func DeleteServer(c *gin.Context) {
 		return
 	}
 
+	writeAuditLog(c, serverID, models.AuditServerDelete, "server", idString(serverID), nil)
+
 	c.JSON(http.StatusNoContent, nil)
 }
 
-func JoinServer(c *gin.Context) {
+type TransferOwnershipPayload struct {
+	UserID   uint64 `json:"user_id,string" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func TransferOwnership(c *gin.Context) {
 	serverID, err := parseServerID(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
 		return
 	}
 
-	userIDObj, _ := c.Get("user_id")
-	userID := userIDObj.(uint64)
+	var payload TransferOwnershipPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	member := currentMember(c)
+	if !member.IsOwner {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the server owner can transfer ownership"})
+		return
+	}
+
+	if payload.UserID == member.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You already own this server"})
+		return
+	}
+
+	// Make the owner re-confirm their password, a stolen session shouldn't be enough
+	userObj, _ := c.Get("user")
+	user := userObj.(models.User)
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		return
+	}
+
+	// The new owner must be an active member
+	if _, err := permissions.Resolve(serverID, payload.UserID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
 
 	var server models.Server
-	if err := database.DB.First(&server, serverID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Guard on the current owner so two racing transfers can't both win
+		result := tx.Model(&models.Server{}).
+			Where("id = ? AND owner_id = ?", serverID, member.UserID).
+			Update("owner_id", payload.UserID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		// Owners can't be timed out, so drop any timeout the new owner was under
+		if err := tx.Model(&models.ServerMember{}).
+			Where("server_id = ? AND user_id = ?", serverID, payload.UserID).
+			Update("timeout_until", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.First(&server, serverID).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer ownership"})
 		return
 	}
 
-	// Check if user is already a member
+	writeAuditLog(c, serverID, models.AuditOwnershipTransfer, "server", idString(serverID), models.AuditChanges{
+		{Key: "owner_id", Old: idString(member.UserID), New: idString(payload.UserID)},
+	})
+
+	// Let every connected client update their owner badges
+	websockets.Manager.Broadcast <- websockets.WsMessage{
+		TargetServerID: serverID,
+		Event:          "SERVER_UPDATE",
+		Data:           server,
+	}
+
+	c.JSON(http.StatusOK, server)
+}
+
+var (
+	errAlreadyMember = errors.New("already a member")
+	errBanned        = errors.New("banned from server")
+)
+
+// Creates or reactivates a membership row. Runs on whatever handle it's given
+// so invite redemption can wrap it in the same transaction as the use counter.
+func addServerMember(db *gorm.DB, serverID uint64, userID uint64, temporary bool) error {
+	// Banned users stay out no matter which door they knock on
+	var banCount int64
+	if err := db.Model(&models.Ban{}).Where("server_id = ? AND user_id = ?", serverID, userID).Count(&banCount).Error; err != nil {
+		return err
+	}
+	if banCount > 0 {
+		return errBanned
+	}
+
 	var existingMember models.ServerMember
-	err = database.DB.Where("server_id = ? AND user_id = ?", serverID, userID).First(&existingMember).Error
+	err := db.Where("server_id = ? AND user_id = ?", serverID, userID).First(&existingMember).Error
 	if err == nil {
 		if existingMember.LeftAt == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this server"})
-			return
-		} else {
-			// They left previously. Rejoin by clearing LeftAt
-			if err := database.DB.Model(&existingMember).Update("left_at", nil).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rejoin server"})
-				return
-			}
-		}
-	} else {
-		// Brand new member
-		newMember := models.ServerMember{
-			ServerID: serverID,
-			UserID:   userID,
-			Role:     "member",
-		}
-		if err := database.DB.Create(&newMember).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join server"})
-			return
+			return errAlreadyMember
 		}
+		// They left previously. Rejoin by clearing LeftAt
+		return db.Model(&existingMember).Updates(map[string]interface{}{
+			"left_at":   nil,
+			"temporary": temporary,
+			"joined_at": time.Now(),
+		}).Error
 	}
 
+	// Brand new member
+	newMember := models.ServerMember{
+		ServerID:  serverID,
+		UserID:    userID,
+		Temporary: temporary,
+	}
+	return db.Create(&newMember).Error
+}
+
+// Subscribes the new member to live events and tells everyone else they arrived
+func announceMemberJoin(serverID uint64, userID uint64) {
 	// Fetch the user's profile to send to everyone else in the server
 	var user models.User
 	database.DB.Select("id", "username", "avatar_url").First(&user, userID)
@@ -289,6 +469,73 @@ func JoinServer(c *gin.Context) {
 		Event:          "SERVER_MEMBER_ADD",
 		Data:           user,
 	}
+}
+
+func RestoreServer(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	// Deleted servers are invisible to the membership middleware, so check ownership here
+	var server models.Server
+	if err := database.DB.Unscoped().
+		Where("id = ? AND owner_id = ? AND deleted_at > ?", serverID, userID, time.Now().Add(-database.Retention)).
+		First(&server).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No restorable server found"})
+		return
+	}
+
+	if err := database.DB.Unscoped().Model(&server).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore server"})
+		return
+	}
+
+	writeAuditLog(c, serverID, models.AuditServerRestore, "server", idString(serverID), nil)
+
+	c.JSON(http.StatusOK, server)
+}
+
+func JoinServer(c *gin.Context) {
+	serverID, err := parseServerID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	var server models.Server
+	if err := database.DB.First(&server, serverID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Server not found"})
+		return
+	}
+
+	// Invite-only servers can't be joined by knowing the ID
+	if !server.AllowDirectJoin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This server can only be joined with an invite"})
+		return
+	}
+
+	if err := addServerMember(database.DB, serverID, userID, false); err != nil {
+		if errors.Is(err, errAlreadyMember) {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already a member of this server"})
+			return
+		}
+		if errors.Is(err, errBanned) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are banned from this server"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join server"})
+		return
+	}
+
+	announceMemberJoin(serverID, userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully joined the server"})
 }
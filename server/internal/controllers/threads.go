@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
+)
+
+// How often idle threads are checked for auto-archiving
+const threadArchiveInterval = time.Minute
+
+// Checks an auto-archive duration against the ones clients can pick from
+func validAutoArchiveDuration(minutes int) bool {
+	for _, allowed := range models.ThreadAutoArchiveDurations {
+		if minutes == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper to grab the channel loaded by RequireChannelPermission, if it's a thread
+func currentThread(c *gin.Context) (models.Channel, bool) {
+	channelObj, _ := c.Get("channel")
+	channel := channelObj.(models.Channel)
+	return channel, channel.IsThread()
+}
+
+// Adds a user to a thread's member list, does nothing if they're already in it
+func joinThread(threadID uint64, userID uint64) (bool, error) {
+	member := models.ThreadMember{ChannelID: threadID, UserID: userID}
+	result := database.DB.Where(&member).FirstOrCreate(&member)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Tells everyone who can see the thread who joined or left it
+func broadcastThreadMembers(thread models.Channel, event string, userID uint64) {
+	broadcastToChannel(*thread.ServerID, thread.ID, "THREAD_MEMBERS_UPDATE", gin.H{
+		"thread_id": idString(thread.ID),
+		event:       []string{idString(userID)},
+	})
+}
+
+// Runs after a message lands in a thread: bumps its activity, brings it back
+// from the archive, and follows it for the author
+func touchThread(thread models.Channel, userID uint64) {
+	now := time.Now()
+	if err := database.DB.Model(&models.Channel{}).Where("id = ?", thread.ID).
+		Updates(map[string]interface{}{"last_activity_at": now, "archived": false}).Error; err != nil {
+		log.Printf("Failed to update activity for thread %d: %v", thread.ID, err)
+	}
+
+	if thread.Archived {
+		thread.Archived = false
+		thread.LastActivityAt = &now
+		withPostTags(database.DB).First(&thread, thread.ID)
+		broadcastToChannel(*thread.ServerID, thread.ID, "THREAD_UPDATE", thread)
+	}
+
+	if joined, err := joinThread(thread.ID, userID); err == nil && joined {
+		broadcastThreadMembers(thread, "added_user_ids", userID)
+	}
+}
+
+// RunThreadArchiver archives threads that have been idle longer than their auto-archive duration.
+// This runs in its own background goroutine (started in main.go).
+func RunThreadArchiver() {
+	ticker := time.NewTicker(threadArchiveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Durations come from a short fixed list, so one query per duration keeps the
+		// cutoff in SQL without needing date arithmetic that differs between databases
+		now := time.Now()
+		var threads []models.Channel
+		for _, minutes := range models.ThreadAutoArchiveDurations {
+			var idle []models.Channel
+			if err := database.DB.Where("type = ? AND archived = ? AND auto_archive_duration = ? AND last_activity_at < ?",
+				models.ChannelTypeThread, false, minutes, now.Add(-time.Duration(minutes)*time.Minute)).
+				Find(&idle).Error; err != nil {
+				log.Printf("Failed to load threads for archiving: %v", err)
+				continue
+			}
+			threads = append(threads, idle...)
+		}
+
+		for _, thread := range threads {
+			// Guard on archived so a message racing in doesn't get overridden
+			result := database.DB.Model(&models.Channel{}).
+				Where("id = ? AND archived = ?", thread.ID, false).
+				Update("archived", true)
+			if result.Error != nil || result.RowsAffected == 0 {
+				continue
+			}
+
+			thread.Archived = true
+			withPostTags(database.DB).First(&thread, thread.ID)
+			broadcastToChannel(*thread.ServerID, thread.ID, "THREAD_UPDATE", thread)
+		}
+	}
+}
+
+type CreateThreadPayload struct {
+	Name                string `json:"name" binding:"required,min=1,max=100"`
+	AutoArchiveDuration int    `json:"auto_archive_duration"` // Minutes, defaults to a day
+}
+
+func CreateThread(c *gin.Context) {
+	serverID, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	var payload CreateThreadPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if payload.AutoArchiveDuration == 0 {
+		payload.AutoArchiveDuration = models.DefaultThreadAutoArchiveDuration
+	}
+	if !validAutoArchiveDuration(payload.AutoArchiveDuration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auto_archive_duration must be one of 60, 1440, 4320 or 10080"})
+		return
+	}
+
+	// Threads only hang off text and announcement channels, no threads inside threads
+	parent, _ := c.Get("channel")
+	if parentType := parent.(models.Channel).Type; parentType != models.ChannelTypeText && parentType != models.ChannelTypeAnnouncement {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Threads can only be started in text or announcement channels"})
+		return
+	}
+
+	var starter models.Message
+	if err := database.DB.Where("id = ? AND channel_id = ?", c.Param("messageID"), channelID).First(&starter).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	var existing int64
+	database.DB.Model(&models.Channel{}).Where("starter_message_id = ?", starter.ID).Count(&existing)
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A thread has already been started from this message"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	now := time.Now()
+	thread := models.Channel{
+		ID:                  utils.GenerateID(),
+		ServerID:            &serverID,
+		Name:                payload.Name,
+		Type:                models.ChannelTypeThread,
+		OwnerID:             &userID,
+		ParentID:            &channelID,
+		StarterMessageID:    &starter.ID,
+		AutoArchiveDuration: payload.AutoArchiveDuration,
+		LastActivityAt:      &now,
+	}
+
+	if err := database.DB.Create(&thread).Error; err != nil {
+		// Lost a race with another request starting a thread on the same message
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A thread has already been started from this message"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create thread"})
+		return
+	}
+
+	// The creator and whoever wrote the starter message follow it from the start
+	joinThread(thread.ID, userID)
+	if starter.AuthorID != nil {
+		joinThread(thread.ID, *starter.AuthorID)
+	}
+
+	broadcastToChannel(serverID, thread.ID, "THREAD_CREATE", thread)
+
+	c.JSON(http.StatusCreated, thread)
+}
+
+func ListThreads(c *gin.Context) {
+	_, channelID, err := verifyChannel(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+		return
+	}
+
+	// Active threads by default, ?archived=true for the archive
+	archived := c.Query("archived") == "true"
+
+	var threads []models.Channel
+	if err := database.DB.Where("parent_id = ? AND type = ? AND archived = ?", channelID, models.ChannelTypeThread, archived).
+		Order("last_activity_at desc").
+		Find(&threads).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch threads"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	if err := attachReadStates(threads, userIDObj.(uint64)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch read states"})
+		return
+	}
+
+	c.JSON(http.StatusOK, threads)
+}
+
+type UpdateThreadPayload struct {
+	Name                *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Archived            *bool   `json:"archived"`
+	AutoArchiveDuration *int    `json:"auto_archive_duration"`
+
+	Locked      *bool     `json:"locked"`       // Only for people who manage channels
+	AppliedTags *[]string `json:"applied_tags"` // Forum posts only, replaces the current tags
+}
+
+func UpdateThread(c *gin.Context) {
+	thread, ok := currentThread(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	var payload UpdateThreadPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Whoever started the thread can manage it, as can anyone who manages channels.
+	// Once it's locked only the latter can.
+	userIDObj, _ := c.Get("user_id")
+	isOwner := thread.OwnerID != nil && *thread.OwnerID == userIDObj.(uint64)
+	isManager := currentChannelPermissions(c).Has(models.PermissionManageChannels)
+	if !isOwner && !isManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to manage this thread"})
+		return
+	}
+	if !isManager && (thread.Locked || payload.Locked != nil) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only moderators can change a locked thread"})
+		return
+	}
+
+	// Tags come from the forum the post is in
+	var tags []models.ForumTag
+	if payload.AppliedTags != nil {
+		var parent models.Channel
+		if err := database.DB.First(&parent, *thread.ParentID).Error; err != nil || !parent.IsForum() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only forum posts can have tags"})
+			return
+		}
+		var err error
+		if tags, err = resolveForumTags(parent.ID, *payload.AppliedTags); err != nil {
+			forumTagErrorResponse(c, err)
+			return
+		}
+	}
+
+	updates := make(map[string]interface{})
+	if payload.Name != nil {
+		updates["name"] = *payload.Name
+	}
+	if payload.AutoArchiveDuration != nil {
+		if !validAutoArchiveDuration(*payload.AutoArchiveDuration) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "auto_archive_duration must be one of 60, 1440, 4320 or 10080"})
+			return
+		}
+		updates["auto_archive_duration"] = *payload.AutoArchiveDuration
+	}
+	if payload.Archived != nil {
+		updates["archived"] = *payload.Archived
+		// Unarchiving restarts the idle clock
+		if !*payload.Archived {
+			updates["last_activity_at"] = time.Now()
+		}
+	}
+	if payload.Locked != nil {
+		updates["locked"] = *payload.Locked
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Channel{}).Where("id = ?", thread.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if payload.AppliedTags != nil {
+			return replacePostTags(tx, thread.ID, tags)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update thread"})
+		return
+	}
+
+	withPostTags(database.DB).First(&thread, thread.ID)
+
+	broadcastToChannel(*thread.ServerID, thread.ID, "THREAD_UPDATE", thread)
+
+	c.JSON(http.StatusOK, thread)
+}
+
+func ListThreadMembers(c *gin.Context) {
+	thread, ok := currentThread(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	var members []models.ThreadMember
+	if err := database.DB.Preload("User").
+		Where("channel_id = ?", thread.ID).
+		Order("joined_at asc").
+		Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch thread members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+func JoinThread(c *gin.Context) {
+	thread, ok := currentThread(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	joined, err := joinThread(thread.ID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join thread"})
+		return
+	}
+
+	if joined {
+		broadcastThreadMembers(thread, "added_user_ids", userID)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func LeaveThread(c *gin.Context) {
+	thread, ok := currentThread(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Thread not found"})
+		return
+	}
+
+	userIDObj, _ := c.Get("user_id")
+	userID := userIDObj.(uint64)
+
+	result := database.DB.Where("channel_id = ? AND user_id = ?", thread.ID, userID).Delete(&models.ThreadMember{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave thread"})
+		return
+	}
+
+	if result.RowsAffected > 0 {
+		broadcastThreadMembers(thread, "removed_user_ids", userID)
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -11,12 +11,14 @@ import (
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
 )
 
+// Sent as JSON, or as multipart with the new picture in an "avatar" file field
 type UpdateUserPayload struct {
-	Username    *string `json:"username" binding:"omitempty,min=3,max=32"`
-	Email       *string `json:"email" binding:"omitempty,email"`
-	DisplayName *string `json:"display_name" binding:"omitempty,min=1,max=32"`
-	AvatarURL   *string `json:"avatar_url" binding:"omitempty,url"`
-	Status      *string `json:"status" binding:"omitempty"`
+	Username     *string `json:"username" form:"username" binding:"omitempty,min=3,max=32"`
+	Email        *string `json:"email" form:"email" binding:"omitempty,email"`
+	DisplayName  *string `json:"display_name" form:"display_name" binding:"omitempty,min=1,max=32"`
+	Status       *string `json:"status" form:"status" binding:"omitempty"`
+	RemoveAvatar bool    `json:"remove_avatar" form:"remove_avatar"` // Back to the generated one
+	AvatarURL    *string `json:"avatar_url" form:"avatar_url"`       // No longer accepted, only here to reject it
 }
 
 func GetCurrentUser(c *gin.Context) {
@@ -43,11 +45,23 @@ func UpdateCurrentUser(c *gin.Context) {
 
 	userID := userIDObj.(uint64)
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUploadSize+1<<20)
+
 	var payload UpdateUserPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err := c.ShouldBind(&payload); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if payload.AvatarURL != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "avatar_url can no longer be set, upload the picture in the avatar field instead"})
+		return
+	}
+
+	avatar, err := decodeUploadedImage(c, "avatar")
+	if err != nil {
+		imageErrorResponse(c, err)
+		return
+	}
 
 	var user models.User
 	// Lookup user by ID
@@ -85,8 +99,15 @@ func UpdateCurrentUser(c *gin.Context) {
 	if payload.DisplayName != nil {
 		updates["display_name"] = *payload.DisplayName
 	}
-	if payload.AvatarURL != nil {
-		updates["avatar_url"] = *payload.AvatarURL
+	if avatar != nil {
+		avatarURL, err := storeProfileImage(avatar)
+		if err != nil {
+			imageErrorResponse(c, err)
+			return
+		}
+		updates["avatar_url"] = avatarURL
+	} else if payload.RemoveAvatar {
+		updates["avatar_url"] = defaultAvatarURL(userID)
 	}
 	if payload.Status != nil {
 		updates["status"] = *payload.Status
@@ -126,6 +147,9 @@ func DeleteCurrentUser(c *gin.Context) {
 	// Scramble the password so they can never log back in
 	database.DB.Model(&models.User{}).Where("id = ?", userID).Update("password_hash", "DELETED_ACCOUNT")
 
+	// Nothing should keep posting in their name
+	database.DB.Where("author_id = ?", userID).Delete(&models.ScheduledMessage{})
+
 	c.JSON(http.StatusNoContent, nil)
 }
 
@@ -29,7 +29,7 @@ func VoiceMembers(c *gin.Context) {
 	// Find all users currently in this channel via the WebRTC VoiceRegistry
 	webrtc.VoiceRegistry.RLock()
 	for userID, client := range webrtc.VoiceRegistry.Clients {
-		if client.ActiveChannelID == channelID {
+		if client.ActiveChannel() == channelID {
 			activeUserIDs = append(activeUserIDs, userID)
 		}
 	}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/jonahgcarpenter/hermes/server/internal/config"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/utils"
 )
 
 var DB *gorm.DB
@@ -21,9 +22,9 @@ func Connect(cfg *config.Config) {
 
 	if dsn == "" {
 		log.Println("DATABASE_URL is not set. Using local SQLite file (hermes.db) as backup.")
-		connection, err = gorm.Open(sqlite.Open("./internal/database/hermes.db"), &gorm.Config{})
+		connection, err = gorm.Open(sqlite.Open("./internal/database/hermes.db"), &gorm.Config{TranslateError: true})
 	} else {
-		connection, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		connection, err = gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	}
 
 	if err != nil {
@@ -37,17 +38,123 @@ func Connect(cfg *config.Config) {
 		log.Fatalf("Failed to setup join table: %v", err)
 	}
 
+	err = connection.SetupJoinTable(&models.Channel{}, "AppliedTags", &models.ForumPostTag{})
+	if err != nil {
+		log.Fatalf("Failed to setup join table: %v", err)
+	}
+
 	err = connection.AutoMigrate(
 		&models.User{},
 		&models.Server{},
 		&models.ServerMember{},
 		&models.Channel{},
+		&models.DMRecipient{},
+		&models.ThreadMember{},
 		&models.Message{},
+		&models.MessageRevision{},
+		&models.ScheduledMessage{},
+		&models.Emoji{},
+		&models.Reaction{},
+		&models.Attachment{},
+		&models.MessageMention{},
+		&models.MessageRoleMention{},
+		&models.ReadState{},
+		&models.Invite{},
+		&models.Role{},
+		&models.MemberRole{},
+		&models.PermissionOverwrite{},
+		&models.ChannelFollower{},
+		&models.ForumTag{},
+		&models.ForumPostTag{},
+		&models.Ban{},
+		&models.AuditLogEntry{},
 	)
 
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
 	}
 
+	if err := setupSearch(connection); err != nil {
+		log.Fatalf("Failed to set up message search: %v", err)
+	}
+
+	// Tag names can't repeat within a forum, whatever their case
+	if err := connection.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_forum_tags_name ON forum_tags (channel_id, LOWER(name))`).Error; err != nil {
+		log.Fatalf("Failed to index forum tag names: %v", err)
+	}
+
+	// Emoji are typed by name, so a name can only be taken once per server
+	if err := connection.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_emojis_name ON emojis (server_id, name)`).Error; err != nil {
+		log.Fatalf("Failed to index emoji names: %v", err)
+	}
+
+	if err := backfillRoles(connection); err != nil {
+		log.Fatalf("Failed to backfill roles: %v", err)
+	}
+
 	DB = connection
+	Retention = cfg.DeletedRetention
+}
+
+// Servers created before roles existed get an @everyone role, and members
+// who held the old "admin" role string get an equivalent Admin role.
+func backfillRoles(db *gorm.DB) error {
+	var serverIDs []uint64
+	if err := db.Model(&models.Server{}).
+		Where("id NOT IN (?)", db.Model(&models.Role{}).Select("server_id").Where("is_default = ?", true)).
+		Pluck("id", &serverIDs).Error; err != nil {
+		return err
+	}
+
+	hasLegacyRoles := db.Migrator().HasColumn("server_members", "role")
+
+	for _, serverID := range serverIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			everyone := models.Role{
+				ID:          utils.GenerateID(),
+				ServerID:    serverID,
+				Name:        "@everyone",
+				Permissions: models.DefaultPermissions,
+				IsDefault:   true,
+			}
+			if err := tx.Create(&everyone).Error; err != nil {
+				return err
+			}
+
+			if !hasLegacyRoles {
+				return nil
+			}
+
+			var adminIDs []uint64
+			if err := tx.Table("server_members").Where("server_id = ? AND role = ?", serverID, "admin").Pluck("user_id", &adminIDs).Error; err != nil {
+				return err
+			}
+			if len(adminIDs) == 0 {
+				return nil
+			}
+
+			admin := models.Role{
+				ID:          utils.GenerateID(),
+				ServerID:    serverID,
+				Name:        "Admin",
+				Position:    1,
+				Permissions: models.DefaultPermissions | models.PermissionManageServer | models.PermissionManageChannels | models.PermissionManageMessages,
+			}
+			if err := tx.Create(&admin).Error; err != nil {
+				return err
+			}
+
+			for _, userID := range adminIDs {
+				if err := tx.Create(&models.MemberRole{ServerID: serverID, UserID: userID, RoleID: admin.ID}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,250 @@
+package database
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/storage"
+)
+
+// Retention is how long soft deleted rows can still be restored. Set by Connect.
+var Retention time.Duration
+
+// How often the purger looks for expired tombstones
+const purgeInterval = time.Hour
+
+// RunPurger hard deletes soft deleted rows once their retention window has passed.
+// This runs in its own background goroutine (started in main.go).
+func RunPurger() {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		purgeExpired()
+		<-ticker.C
+	}
+}
+
+func purgeExpired() {
+	cutoff := time.Now().Add(-Retention)
+
+	// Servers first, they take their channels and messages with them
+	var serverIDs []uint64
+	DB.Unscoped().Model(&models.Server{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &serverIDs)
+	for _, id := range serverIDs {
+		var orphaned []string
+		if err := DB.Transaction(func(tx *gorm.DB) error { return purgeServer(tx, id, &orphaned) }); err != nil {
+			log.Printf("Failed to purge server %d: %v", id, err)
+			continue
+		}
+		deleteStoredFiles(orphaned)
+	}
+
+	var channelIDs []uint64
+	DB.Unscoped().Model(&models.Channel{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Pluck("id", &channelIDs)
+	for _, id := range channelIDs {
+		var orphaned []string
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			return purgeChannels(tx, tx.Unscoped().Model(&models.Channel{}).Select("id").Where("id = ?", id), &orphaned)
+		})
+		if err != nil {
+			log.Printf("Failed to purge channel %d: %v", id, err)
+			continue
+		}
+		deleteStoredFiles(orphaned)
+	}
+
+	var messageCount int64
+	DB.Unscoped().Model(&models.Message{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Count(&messageCount)
+	if messageCount > 0 {
+		var orphaned []string
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			return purgeMessages(tx, tx.Unscoped().Model(&models.Message{}).Select("id").Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff), &orphaned)
+		})
+		if err != nil {
+			log.Printf("Failed to purge %d messages: %v", messageCount, err)
+		} else {
+			deleteStoredFiles(orphaned)
+		}
+	}
+
+	if total := int64(len(serverIDs)+len(channelIDs)) + messageCount; total > 0 {
+		log.Printf("Purged %d servers, %d channels and %d messages past retention", len(serverIDs), len(channelIDs), messageCount)
+	}
+
+	pruneRevisions()
+}
+
+// Drops edit history older than each server's revision retention setting.
+// Servers are grouped by setting so this is one delete per distinct value, not per server.
+func pruneRevisions() {
+	var settings []int
+	DB.Model(&models.Server{}).Where("revision_retention_days > 0").Distinct().Pluck("revision_retention_days", &settings)
+
+	for _, days := range settings {
+		servers := DB.Model(&models.Server{}).Select("id").Where("revision_retention_days = ?", days)
+		channels := DB.Unscoped().Model(&models.Channel{}).Select("id").Where("server_id IN (?)", servers)
+		messages := DB.Unscoped().Model(&models.Message{}).Select("id").Where("channel_id IN (?)", channels)
+
+		result := DB.Where("created_at < ? AND message_id IN (?)", time.Now().AddDate(0, 0, -days), messages).Delete(&models.MessageRevision{})
+		if result.Error != nil {
+			log.Printf("Failed to prune message revisions older than %d days: %v", days, result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("Pruned %d message revisions older than %d days", result.RowsAffected, days)
+		}
+	}
+}
+
+// Removes files whose rows are gone. Only called once the purge has committed, a rolled
+// back purge keeps its rows and they still need their files. Best effort, a leftover object is harmless.
+func deleteStoredFiles(keys []string) {
+	for _, key := range keys {
+		if err := storage.Store.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to remove stored file %s: %v", key, err)
+		}
+	}
+}
+
+// Permanently removes a server and every row that hangs off it.
+// Storage keys nothing points at anymore are added to orphaned.
+func purgeServer(tx *gorm.DB, serverID uint64, orphaned *[]string) error {
+	channels := tx.Unscoped().Model(&models.Channel{}).Select("id").Where("server_id = ?", serverID)
+	if err := purgeChannels(tx, channels, orphaned); err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{
+		&models.Invite{},
+		&models.MemberRole{},
+		&models.Role{},
+		&models.Ban{},
+		&models.Emoji{},
+		&models.AuditLogEntry{},
+		&models.ServerMember{},
+	} {
+		if err := tx.Where("server_id = ?", serverID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Unscoped().Delete(&models.Server{}, serverID).Error
+}
+
+// Permanently removes channels along with their threads, messages and overwrites.
+// The channels and messages are passed around as subqueries, a big server can have
+// more rows than the database allows bind parameters in one statement.
+func purgeChannels(tx *gorm.DB, channels *gorm.DB, orphaned *[]string) error {
+	channels = tx.Unscoped().Model(&models.Channel{}).Select("id").
+		Where("id IN (?) OR (type = ? AND parent_id IN (?))", channels, models.ChannelTypeThread, channels)
+
+	messages := tx.Unscoped().Model(&models.Message{}).Select("id").Where("channel_id IN (?)", channels)
+	if err := purgeMessages(tx, messages, orphaned); err != nil {
+		return err
+	}
+
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.PermissionOverwrite{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("source_channel_id IN (?) OR target_channel_id IN (?)", channels, channels).
+		Delete(&models.ChannelFollower{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("thread_id IN (?)", channels).Delete(&models.ForumPostTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.ForumTag{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.DMRecipient{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.ThreadMember{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.ReadState{}).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Where("channel_id IN (?)", channels).Delete(&models.ScheduledMessage{}).Error; err != nil {
+		return err
+	}
+
+	// Invites pointing at a purged channel still work, they just land on the server
+	if err := tx.Model(&models.Invite{}).Where("channel_id IN (?)", channels).Update("channel_id", nil).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Where("id IN (?)", channels).Delete(&models.Channel{}).Error
+}
+
+// Permanently removes the messages a subquery selects
+func purgeMessages(tx *gorm.DB, messages *gorm.DB, orphaned *[]string) error {
+	if err := tx.Where("message_id IN (?)", messages).Delete(&models.Reaction{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN (?)", messages).Delete(&models.MessageMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN (?)", messages).Delete(&models.MessageRoleMention{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id IN (?)", messages).Delete(&models.MessageRevision{}).Error; err != nil {
+		return err
+	}
+
+	// Files are collected here and removed from storage after the commit
+	var attachments []models.Attachment
+	if err := tx.Select("storage_key", "thumbnail_key").Where("message_id IN (?)", messages).Find(&attachments).Error; err != nil {
+		return err
+	}
+
+	// Published announcements share their files with the copies, keep whatever is still in use
+	inUse := make(map[string]bool)
+	if len(attachments) > 0 {
+		purgedKeys := tx.Model(&models.Attachment{}).Select("storage_key").Where("message_id IN (?)", messages)
+		purgedThumbnails := tx.Model(&models.Attachment{}).Select("thumbnail_key").Where("message_id IN (?) AND thumbnail_key <> ''", messages)
+		var remaining []models.Attachment
+		if err := tx.Select("storage_key", "thumbnail_key").
+			Where("message_id NOT IN (?) AND (storage_key IN (?) OR thumbnail_key IN (?))", messages, purgedKeys, purgedThumbnails).
+			Find(&remaining).Error; err != nil {
+			return err
+		}
+		for _, attachment := range remaining {
+			inUse[attachment.StorageKey] = true
+			inUse[attachment.ThumbnailKey] = true
+		}
+	}
+	for _, attachment := range attachments {
+		if !inUse[attachment.StorageKey] {
+			*orphaned = append(*orphaned, attachment.StorageKey)
+		}
+		if attachment.ThumbnailKey != "" && !inUse[attachment.ThumbnailKey] {
+			*orphaned = append(*orphaned, attachment.ThumbnailKey)
+		}
+	}
+
+	if err := tx.Where("message_id IN (?)", messages).Delete(&models.Attachment{}).Error; err != nil {
+		return err
+	}
+
+	// Replies and threads outlive the message they point at
+	if err := tx.Unscoped().Model(&models.Message{}).Where("referenced_message_id IN (?)", messages).
+		Update("referenced_message_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Model(&models.Channel{}).Where("starter_message_id IN (?)", messages).
+		Update("starter_message_id", nil).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Where("id IN (?)", messages).Delete(&models.Message{}).Error
+}
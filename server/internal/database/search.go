@@ -0,0 +1,68 @@
+package database
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Full text search over message content. Postgres searches a GIN index over to_tsvector,
+// SQLite keeps an FTS5 table in sync with triggers. Both stem English words so
+// "running" finds "run" either way.
+
+func setupSearch(db *gorm.DB) error {
+	if db.Dialector.Name() == "postgres" {
+		return db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_content_search ON messages USING GIN (to_tsvector('english', content))`).Error
+	}
+
+	// The FTS table only stores the index, the text itself stays in messages
+	existed := db.Migrator().HasTable("messages_fts")
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(content, content='messages', content_rowid='id', tokenize='porter unicode61')`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+
+	// Index whatever was sent before search existed
+	if !existed {
+		return db.Exec(`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`).Error
+	}
+	return nil
+}
+
+// MatchMessages narrows a query on messages down to ones whose content matches the search text
+func MatchMessages(query *gorm.DB, text string) *gorm.DB {
+	if DB.Dialector.Name() == "postgres" {
+		return query.Where("to_tsvector('english', messages.content) @@ websearch_to_tsquery('english', ?)", text)
+	}
+	return query.Where("messages.id IN (SELECT rowid FROM messages_fts WHERE messages_fts MATCH ?)", ftsQuery(text))
+}
+
+// Quotes every word so user input can't be read as FTS5 syntax, the words are then ANDed together
+func ftsQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		word = strings.ReplaceAll(word, `"`, "")
+		if word != "" {
+			terms = append(terms, `"`+word+`"`)
+		}
+	}
+	// Nothing left to look for, match nothing rather than erroring
+	if len(terms) == 0 {
+		return `""`
+	}
+	return strings.Join(terms, " ")
+}
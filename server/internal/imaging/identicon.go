@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+const (
+	identiconGrid = 5
+	identiconCell = 96
+	identiconPad  = 16
+)
+
+// Identicon draws the default avatar for a seed, a mirrored 5x5 pattern in a colour
+// picked from the seed so the same name always gets the same picture
+func Identicon(seed string) image.Image {
+	sum := sha256.Sum256([]byte(seed))
+
+	fg := hslColor(float64(sum[0])/255*360, 0.55, 0.55)
+	bg := color.NRGBA{R: 0xF0, G: 0xF0, B: 0xF0, A: 0xFF}
+
+	size := identiconGrid*identiconCell + 2*identiconPad
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{bg}, image.Point{}, draw.Src)
+
+	// Only the left three columns come from the hash, the right two mirror them
+	bit := 0
+	for x := 0; x < (identiconGrid+1)/2; x++ {
+		for y := 0; y < identiconGrid; y++ {
+			on := sum[1+bit/8]>>(bit%8)&1 == 1
+			bit++
+			if !on {
+				continue
+			}
+			for _, col := range []int{x, identiconGrid - 1 - x} {
+				cell := image.Rect(0, 0, identiconCell, identiconCell).
+					Add(image.Pt(identiconPad+col*identiconCell, identiconPad+y*identiconCell))
+				draw.Draw(img, cell, &image.Uniform{fg}, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+func hslColor(h, s, l float64) color.NRGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g = c, x
+	case hp < 2:
+		r, g = x, c
+	case hp < 3:
+		g, b = c, x
+	case hp < 4:
+		g, b = x, c
+	case hp < 5:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+
+	m := l - c/2
+	return color.NRGBA{R: uint8((r + m) * 255), G: uint8((g + m) * 255), B: uint8((b + m) * 255), A: 0xFF}
+}
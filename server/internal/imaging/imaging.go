@@ -0,0 +1,144 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	_ "image/gif" // Registered so image.Decode understands them
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/buckket/go-blurhash"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Anything bigger than this is refused before decoding so a tiny file
+// can't claim huge dimensions and eat all our memory
+const MaxPixels = 40_000_000
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image dimensions too large")
+)
+
+// Decode reads a GIF, JPEG, PNG or WebP and returns it the right way up.
+// Only pixels come back, metadata like EXIF never makes it past here.
+// Animated images only keep their first frame.
+func Decode(r io.ReadSeeker) (image.Image, error) {
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	// Phones save photos sideways and leave a note in EXIF, read it before it's gone
+	orientation := 1
+	if format == "jpeg" {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		orientation = jpegOrientation(r)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	return orient(img, orientation), nil
+}
+
+// Fit scales an image down to fit inside maxWidth by maxHeight, keeping its shape.
+// Images that already fit are returned as is.
+func Fit(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+
+	if w*maxHeight > h*maxWidth {
+		h = max(1, h*maxWidth/w)
+		w = maxWidth
+	} else {
+		w = max(1, w*maxHeight/h)
+		h = maxHeight
+	}
+	return scale(img, b, w, h)
+}
+
+// Square crops the middle of an image into a square and scales it to size
+func Square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return scale(img, image.Rect(x, y, x+side, y+side), size, size)
+}
+
+func scale(img image.Image, src image.Rectangle, w, h int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+// EncodePNG writes an image as a PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG writes an image as a JPEG. Photos stay photos, lossless would balloon them.
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeWebP writes an image as a lossless WebP
+func EncodeWebP(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := nativewebp.Encode(&buf, img, nil); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Blurhash builds the short placeholder string clients show while the real image loads
+func Blurhash(img image.Image) (string, error) {
+	// The hash only keeps a handful of colours, so a tiny copy gives the same result much faster
+	small := Fit(img, 32, 32)
+
+	xComponents, yComponents := 4, 3
+	if b := small.Bounds(); b.Dy() > b.Dx() {
+		xComponents, yComponents = 3, 4
+	}
+	return blurhash.Encode(xComponents, yComponents, small)
+}
+
+// Makes a tightly packed copy with its origin at 0,0 so pixels can be indexed directly
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) && n.Stride == 4*n.Rect.Dx() {
+		return n
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+)
+
+// RequireChannelPermission checks a permission after channel overwrites are applied
+func RequireChannelPermission(requiredPermission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, exists := c.Get("user_id")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDObj.(uint64)
+
+		serverID, err := strconv.ParseUint(c.Param("serverID"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid server ID format"})
+			return
+		}
+
+		channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID format"})
+			return
+		}
+
+		member, err := permissions.Resolve(serverID, userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You must be a member to perform this action"})
+			return
+		}
+
+		// Ensure the channel exists AND belongs to the server in the URL path
+		var channel models.Channel
+		if err := database.DB.Where("id = ? AND server_id = ?", channelID, serverID).First(&channel).Error; err != nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+			return
+		}
+
+		// Threads pick up their parent's overwrites here
+		overwrites, err := permissions.ChannelOverwrites(channel.ID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve channel permissions"})
+			return
+		}
+
+		// Hidden channels look the same as missing ones
+		channelPerms := member.InChannel(overwrites)
+		if !channelPerms.Has(models.PermissionViewChannels) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Channel not found in this server"})
+			return
+		}
+
+		if !channelPerms.Has(requiredPermission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this in this channel"})
+			return
+		}
+
+		c.Set("member", member)
+		c.Set("channel", channel)
+		c.Set("channel_permissions", channelPerms)
+		c.Next()
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+)
+
+// DM participants can read and talk, but nobody moderates a DM
+const dmPermissions = models.PermissionViewChannels | models.PermissionSendMessages
+
+// RequireDMRecipient ensures the user is part of the DM or group DM in the URL
+func RequireDMRecipient() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userIDObj, exists := c.Get("user_id")
+		if !exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDObj.(uint64)
+
+		channelID, err := strconv.ParseUint(c.Param("channelID"), 10, 64)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID format"})
+			return
+		}
+
+		// Other people's DMs look the same as missing ones
+		if !permissions.IsDMRecipient(channelID, userID) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+			return
+		}
+
+		c.Set("channel_permissions", models.Permission(dmPermissions))
+		c.Next()
+	}
+}
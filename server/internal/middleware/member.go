@@ -5,8 +5,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
 )
 
 func RequireMembership() gin.HandlerFunc {
@@ -25,21 +25,21 @@ func RequireMembership() gin.HandlerFunc {
 			return
 		}
 
-		var member models.ServerMember
 		// Check if they are in the server AND haven't left
-		if err := database.DB.Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, userID).First(&member).Error; err != nil {
+		member, err := permissions.Resolve(serverID, userID)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not an active member of this server"})
 			return
 		}
 
-		// Store the user's role in the context so subsequent
-		c.Set("server_role", member.Role)
+		// Store the resolved member in the context so handlers can check permissions
+		c.Set("member", member)
 
 		c.Next()
 	}
 }
 
-func RequirePermission(requiredPermission string) gin.HandlerFunc {
+func RequirePermission(requiredPermission models.Permission) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userIDObj, exists := c.Get("user_id")
 		if !exists {
@@ -55,40 +55,19 @@ func RequirePermission(requiredPermission string) gin.HandlerFunc {
 			return
 		}
 
-		var member models.ServerMember
-		if err := database.DB.Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, userID).First(&member).Error; err != nil {
+		member, err := permissions.Resolve(serverID, userID)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You must be a member to perform this action"})
 			return
 		}
 
-		// Check if their role grants them the required permission
-		if !hasPermission(member.Role, requiredPermission) {
+		// Check if their roles grant them the required permission
+		if !member.Has(requiredPermission) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
 			return
 		}
 
-		c.Set("server_role", member.Role)
+		c.Set("member", member)
 		c.Next()
 	}
 }
-
-func hasPermission(userRole string, required string) bool {
-	// Owners can do absolutely anything
-	if userRole == "owner" {
-		return true
-	}
-
-	switch required {
-	case "manage_server", "manage_channels", "delete_messages":
-		// Only admins and owners can do these destructive/administrative actions
-		return userRole == "admin"
-
-	case "send_messages", "join_voice":
-		// Regular members (and admins/owners) can do basic interactions
-		return userRole == "member" || userRole == "admin"
-
-	default:
-		// If we don't recognize the permission, default to deny for safety
-		return false
-	}
-}
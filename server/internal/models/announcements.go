@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// How many announcement channels one channel can follow
+const MaxFollowsPerChannel = 10
+
+// ChannelFollower copies everything published in an announcement channel into a text channel in another server
+type ChannelFollower struct {
+	ID              uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	SourceChannelID uint64 `gorm:"not null;uniqueIndex:idx_follow_pair" json:"source_channel_id,string"`
+	SourceServerID  uint64 `gorm:"not null" json:"source_server_id,string"`
+	TargetChannelID uint64 `gorm:"not null;uniqueIndex:idx_follow_pair;index" json:"target_channel_id,string"`
+	TargetServerID  uint64 `gorm:"not null;index" json:"target_server_id,string"`
+	CreatedByID     uint64 `gorm:"not null" json:"created_by_id,string"`
+
+	// Relationships, each side loads the other end of the follow
+	SourceChannel *Channel `gorm:"foreignKey:SourceChannelID" json:"source_channel,omitempty"`
+	SourceServer  *Server  `gorm:"foreignKey:SourceServerID" json:"source_server,omitempty"`
+	TargetChannel *Channel `gorm:"foreignKey:TargetChannelID" json:"target_channel,omitempty"`
+	TargetServer  *Server  `gorm:"foreignKey:TargetServerID" json:"target_server,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is an uploaded file sent along with a message
+type Attachment struct {
+	ID          uint64  `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	MessageID   uint64  `gorm:"not null;index" json:"-"`
+	ChannelID   uint64  `gorm:"not null;index" json:"-"`
+	ServerID    *uint64 `gorm:"index" json:"-"` // nil for DMs and published copies, counts towards the server's upload quota
+	UploaderID  uint64  `gorm:"not null" json:"-"`
+	Filename    string  `gorm:"not null;size:255" json:"filename"`
+	Size        int64   `gorm:"not null" json:"size"`
+	ContentType string  `gorm:"not null;size:255" json:"content_type"`
+	Width       *int    `json:"width,omitempty"` // Images only
+	Height      *int    `json:"height,omitempty"`
+	StorageKey  string  `gorm:"not null" json:"-"`
+
+	// Images get a small WebP preview and a blurhash to show while loading
+	ThumbnailKey string `json:"-"`
+	Blurhash     string `gorm:"size:64" json:"blurhash,omitempty"`
+
+	// Authenticated download links, filled in after loading
+	URL          string `gorm:"-" json:"url"`
+	ThumbnailURL string `gorm:"-" json:"thumbnail_url,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AfterFind builds the download URL, the filename on the end is just for browsers
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.URL = "/api/attachments/" + strconv.FormatUint(a.ID, 10) + "/" + url.PathEscape(a.Filename)
+	if a.ThumbnailKey != "" {
+		a.ThumbnailURL = a.URL + "?thumbnail=true"
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditServerUpdate      AuditAction = "SERVER_UPDATE"
+	AuditServerDelete      AuditAction = "SERVER_DELETE"
+	AuditServerRestore     AuditAction = "SERVER_RESTORE"
+	AuditOwnershipTransfer AuditAction = "OWNERSHIP_TRANSFER"
+
+	AuditChannelCreate          AuditAction = "CHANNEL_CREATE"
+	AuditChannelUpdate          AuditAction = "CHANNEL_UPDATE"
+	AuditChannelDelete          AuditAction = "CHANNEL_DELETE"
+	AuditChannelRestore         AuditAction = "CHANNEL_RESTORE"
+	AuditChannelOverwriteUpdate AuditAction = "CHANNEL_OVERWRITE_UPDATE"
+	AuditChannelOverwriteDelete AuditAction = "CHANNEL_OVERWRITE_DELETE"
+	AuditChannelFollowCreate    AuditAction = "CHANNEL_FOLLOW_CREATE"
+	AuditChannelFollowDelete    AuditAction = "CHANNEL_FOLLOW_DELETE"
+
+	AuditMemberKick       AuditAction = "MEMBER_KICK"
+	AuditMemberBanAdd     AuditAction = "MEMBER_BAN_ADD"
+	AuditMemberBanRemove  AuditAction = "MEMBER_BAN_REMOVE"
+	AuditMemberTimeout    AuditAction = "MEMBER_TIMEOUT"
+	AuditMemberRoleAdd    AuditAction = "MEMBER_ROLE_ADD"
+	AuditMemberRoleRemove AuditAction = "MEMBER_ROLE_REMOVE"
+
+	AuditRoleCreate AuditAction = "ROLE_CREATE"
+	AuditRoleUpdate AuditAction = "ROLE_UPDATE"
+	AuditRoleDelete AuditAction = "ROLE_DELETE"
+
+	AuditInviteCreate AuditAction = "INVITE_CREATE"
+	AuditInviteDelete AuditAction = "INVITE_DELETE"
+
+	AuditEmojiCreate AuditAction = "EMOJI_CREATE"
+	AuditEmojiDelete AuditAction = "EMOJI_DELETE"
+
+	AuditMessageDelete  AuditAction = "MESSAGE_DELETE"
+	AuditMessageRestore AuditAction = "MESSAGE_RESTORE"
+	AuditMessagePin     AuditAction = "MESSAGE_PIN"
+	AuditMessageUnpin   AuditAction = "MESSAGE_UNPIN"
+
+	AuditMessageBulkDelete AuditAction = "MESSAGE_BULK_DELETE"
+)
+
+// AuditChange is one field that changed, with its value before and after
+type AuditChange struct {
+	Key string      `json:"key"`
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AuditChanges is stored as a JSON text column
+type AuditChanges []AuditChange
+
+func (a AuditChanges) Value() (driver.Value, error) {
+	if len(a) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
+func (a *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case []byte:
+		return json.Unmarshal(v, a)
+	default:
+		return errors.New("unsupported type for AuditChanges")
+	}
+}
+
+type AuditLogEntry struct {
+	ID         uint64       `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ServerID   uint64       `gorm:"not null;index" json:"server_id,string"`
+	ActorID    uint64       `gorm:"not null;index" json:"actor_id,string"`
+	Action     AuditAction  `gorm:"not null;size:64;index" json:"action"`
+	TargetType string       `gorm:"size:32" json:"target_type,omitempty"` // "server", "channel", "member", "role", "invite" or "message"
+	TargetID   string       `gorm:"size:32;index" json:"target_id,omitempty"`
+	Changes    AuditChanges `gorm:"type:text" json:"changes"`
+	Reason     string       `gorm:"size:512" json:"reason,omitempty"`
+
+	// Relationships
+	Actor User `gorm:"foreignKey:ActorID" json:"actor"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type Ban struct {
+	ServerID    uint64 `gorm:"primaryKey;autoIncrement:false" json:"server_id,string"`
+	UserID      uint64 `gorm:"primaryKey;autoIncrement:false" json:"user_id,string"`
+	ModeratorID uint64 `gorm:"not null" json:"moderator_id,string"`
+	Reason      string `gorm:"size:512" json:"reason"`
+
+	// Relationships
+	User   User   `gorm:"foreignKey:UserID" json:"user"`
+	Server Server `gorm:"foreignKey:ServerID" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
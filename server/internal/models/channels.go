@@ -1,24 +1,110 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ChannelType string
 
 const (
-	ChannelTypeText  ChannelType = "TEXT"
-	ChannelTypeVoice ChannelType = "VOICE"
+	ChannelTypeText         ChannelType = "TEXT"
+	ChannelTypeVoice        ChannelType = "VOICE"
+	ChannelTypeDM           ChannelType = "DM"
+	ChannelTypeGroupDM      ChannelType = "GROUP_DM"
+	ChannelTypeThread       ChannelType = "THREAD"
+	ChannelTypeCategory     ChannelType = "CATEGORY"     // Groups other server channels, holds no messages
+	ChannelTypeAnnouncement ChannelType = "ANNOUNCEMENT" // A text channel other servers can follow
+	ChannelTypeForum        ChannelType = "FORUM"        // Every post is a thread, the forum itself holds no messages
 )
 
+// MaxGroupDMRecipients caps how many people can share a group DM
+const MaxGroupDMRecipients = 10
+
 type Channel struct {
 	ID       uint64      `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
-	ServerID uint64      `gorm:"not null;index" json:"server_id,string"`
+	ServerID *uint64     `gorm:"index" json:"server_id,string,omitempty"` // nil for DMs
 	Name     string      `gorm:"not null;size:100" json:"name"`
 	Type     ChannelType `gorm:"not null;default:'TEXT'" json:"type"`
 	Position int         `gorm:"not null;default:0" json:"position"`
+	Topic    string      `gorm:"not null;size:1024;default:''" json:"topic"`
+	NSFW     bool        `gorm:"not null;default:false" json:"nsfw"`
+
+	// The group DM owner who can remove other recipients, or the user who started a thread
+	OwnerID *uint64 `json:"owner_id,string,omitempty"`
+
+	// For threads the channel they were started in, for other server channels the category they sit in
+	ParentID *uint64 `gorm:"index" json:"parent_id,string,omitempty"`
+
+	// Threads only, see threads.go
+	StarterMessageID    *uint64    `gorm:"uniqueIndex" json:"starter_message_id,string,omitempty"`
+	Archived            bool       `gorm:"not null;default:false" json:"archived"`
+	AutoArchiveDuration int        `gorm:"not null;default:0" json:"auto_archive_duration,omitempty"` // Minutes of inactivity
+	LastActivityAt      *time.Time `json:"last_activity_at,omitempty"`
+	Locked              bool       `gorm:"not null;default:false" json:"locked"` // Only people who manage channels can post or reopen it
+
+	// Forums only, the tags their posts can pick from
+	AvailableTags []ForumTag `gorm:"foreignKey:ChannelID" json:"available_tags,omitempty"`
+
+	// Forum posts only
+	AppliedTags []ForumTag `gorm:"many2many:forum_post_tags;joinForeignKey:ThreadID;joinReferences:TagID" json:"applied_tags,omitempty"`
 
 	// Relationships
-	Messages []Message `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Messages      []Message             `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+	Overwrites    []PermissionOverwrite `gorm:"constraint:OnDelete:CASCADE;" json:"permission_overwrites,omitempty"`
+	Recipients    []DMRecipient         `gorm:"constraint:OnDelete:CASCADE;" json:"recipients,omitempty"`
+	ThreadMembers []ThreadMember        `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+
+	// Seconds each member has to wait between messages, 0 is off
+	SlowModeSeconds int `gorm:"not null;default:0" json:"slow_mode_seconds"`
+
+	// The requesting user's read marker, filled in when channels are listed
+	ReadState *ReadState `gorm:"-" json:"read_state,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// IsCategory reports whether the channel only exists to group others
+func (ch *Channel) IsCategory() bool {
+	return ch.Type == ChannelTypeCategory
+}
+
+// IsForum reports whether the channel is a forum, whose posts are threads
+func (ch *Channel) IsForum() bool {
+	return ch.Type == ChannelTypeForum
+}
+
+// IsDM reports whether the channel lives outside of any server
+func (ch *Channel) IsDM() bool {
+	return ch.Type == ChannelTypeDM || ch.Type == ChannelTypeGroupDM
+}
+
+// DMRecipient is one participant of a DM or group DM channel
+type DMRecipient struct {
+	ChannelID uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	UserID    uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"user_id,string"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID" json:"user"`
+
+	JoinedAt time.Time `gorm:"autoCreateTime" json:"joined_at"`
+}
+
+type OverwriteType string
+
+const (
+	OverwriteTypeRole   OverwriteType = "ROLE"
+	OverwriteTypeMember OverwriteType = "MEMBER"
+)
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+// PermissionOverwrite adjusts server permissions for one role or member inside one channel
+type PermissionOverwrite struct {
+	ChannelID uint64        `gorm:"primaryKey;autoIncrement:false" json:"channel_id,string"`
+	TargetID  uint64        `gorm:"primaryKey;autoIncrement:false" json:"target_id,string"` // Role ID or User ID depending on Type
+	Type      OverwriteType `gorm:"not null" json:"type"`
+	Allow     Permission    `gorm:"not null;default:0" json:"allow,string"`
+	Deny      Permission    `gorm:"not null;default:0" json:"deny,string"`
 }
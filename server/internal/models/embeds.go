@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+// Embed is a preview of a link posted in a message, filled in by the server after it's sent
+type Embed struct {
+	URL         string      `json:"url"`
+	Type        string      `json:"type"` // "link", "image", "video" or "rich"
+	Title       string      `json:"title,omitempty"`
+	Description string      `json:"description,omitempty"`
+	SiteName    string      `json:"site_name,omitempty"`
+	AuthorName  string      `json:"author_name,omitempty"`
+	Image       *EmbedMedia `json:"image,omitempty"`
+	Video       *EmbedMedia `json:"video,omitempty"`
+}
+
+// EmbedMedia points at an image or video hosted by the linked site
+type EmbedMedia struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Embeds is stored as a JSON text column
+type Embeds []Embed
+
+func (e Embeds) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return "[]", nil
+	}
+	data, err := json.Marshal(e)
+	return string(data), err
+}
+
+func (e *Embeds) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*e = Embeds{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	case []byte:
+		return json.Unmarshal(v, e)
+	default:
+		return errors.New("unsupported type for Embeds")
+	}
+}
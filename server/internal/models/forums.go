@@ -0,0 +1,21 @@
+package models
+
+// How many tags a forum can offer, and how many of them one post can carry
+const (
+	MaxTagsPerForum = 20
+	MaxTagsPerPost  = 5
+)
+
+// ForumTag is a label a forum offers for sorting its posts
+type ForumTag struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ChannelID uint64 `gorm:"not null;index" json:"channel_id,string"`
+	Name      string `gorm:"not null;size:50" json:"name"`
+	Emoji     string `gorm:"size:64" json:"emoji,omitempty"` // A unicode emoji shown next to the name
+}
+
+// ForumPostTag puts one tag on one forum post, the join table behind Channel.AppliedTags
+type ForumPostTag struct {
+	ThreadID uint64 `gorm:"primaryKey;autoIncrement:false"`
+	TagID    uint64 `gorm:"primaryKey;autoIncrement:false;index"`
+}
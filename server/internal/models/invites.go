@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+type Invite struct {
+	Code      string  `gorm:"primaryKey;size:16" json:"code"`
+	ServerID  uint64  `gorm:"not null;index" json:"server_id,string"`
+	ChannelID *uint64 `json:"channel_id,string,omitempty"`
+	CreatorID uint64  `gorm:"not null;index" json:"creator_id,string"`
+	MaxUses   int     `gorm:"not null;default:0" json:"max_uses"` // 0 means unlimited
+	Uses      int     `gorm:"not null;default:0" json:"uses"`
+	Temporary bool    `gorm:"not null;default:false" json:"temporary"`
+
+	// Relationships
+	Server  Server   `gorm:"foreignKey:ServerID" json:"-"`
+	Channel *Channel `gorm:"foreignKey:ChannelID" json:"-"`
+	Creator User     `gorm:"foreignKey:CreatorID" json:"creator"`
+
+	ExpiresAt *time.Time `json:"expires_at"` // nil means it never expires
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// IsUsable reports whether the invite can still be redeemed right now
+func (i *Invite) IsUsable() bool {
+	if i.RevokedAt != nil {
+		return false
+	}
+	if i.ExpiresAt != nil && time.Now().After(*i.ExpiresAt) {
+		return false
+	}
+	if i.MaxUses > 0 && i.Uses >= i.MaxUses {
+		return false
+	}
+	return true
+}
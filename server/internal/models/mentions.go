@@ -0,0 +1,15 @@
+package models
+
+// MessageMention is a user pinged with <@id> in a message
+type MessageMention struct {
+	MessageID uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	UserID    uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"user_id,string"`
+
+	User User `gorm:"foreignKey:UserID" json:"user"`
+}
+
+// MessageRoleMention is a role pinged with <@&id> in a message
+type MessageRoleMention struct {
+	MessageID uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	RoleID    uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"role_id,string"`
+}
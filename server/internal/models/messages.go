@@ -2,18 +2,71 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Message struct {
-	ID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
-	ChannelID uint64 `gorm:"not null;index" json:"channel_id,string"`
-	AuthorID  uint64 `gorm:"not null;index" json:"author_id,string"`
-	Content   string `gorm:"type:text;not null" json:"content"`
+	ID        uint64  `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ChannelID uint64  `gorm:"not null;index" json:"channel_id,string"`
+	AuthorID  *uint64 `gorm:"index" json:"author_id,string,omitempty"` // Empty on announcement copies, those come from a server
+
+	Content string `gorm:"type:text;not null" json:"content"`
+
+	// Set when this message is an inline reply
+	ReferencedMessageID *uint64 `gorm:"index" json:"referenced_message_id,string,omitempty"`
+
+	// True for both @everyone and @here, as long as the author was allowed to use them
+	MentionEveryone bool `gorm:"not null;default:false" json:"mention_everyone"`
 
 	// Relationships
-	Author  User    `gorm:"foreignKey:AuthorID" json:"author"`
-	Channel Channel `gorm:"foreignKey:ChannelID" json:"-"`
+	Author            *User                `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+	Channel           Channel              `gorm:"foreignKey:ChannelID" json:"-"`
+	ReferencedMessage *Message             `gorm:"foreignKey:ReferencedMessageID" json:"referenced_message,omitempty"`
+	Thread            *Channel             `gorm:"foreignKey:StarterMessageID" json:"thread,omitempty"` // Thread started from this message
+	Attachments       []Attachment         `gorm:"constraint:OnDelete:CASCADE;" json:"attachments,omitempty"`
+	Mentions          []MessageMention     `gorm:"constraint:OnDelete:CASCADE;" json:"mentions"`
+	MentionRoles      []MessageRoleMention `gorm:"constraint:OnDelete:CASCADE;" json:"mention_roles"`
+
+	// Link previews, added in the background a moment after the message is sent
+	Embeds Embeds `gorm:"type:text;not null;default:'[]'" json:"embeds"`
+
+	// Aggregated per request since "me" depends on who is asking
+	Reactions []ReactionCount `gorm:"-" json:"reactions,omitempty"`
+
+	// Only set when the content was actually changed, null for messages never edited
+	EditedAt *time.Time `json:"edited_at"`
+
+	// Set while the message is pinned to its channel
+	PinnedAt *time.Time `gorm:"index" json:"pinned_at"`
+
+	// Set on announcements once they have been copied out to the channels following them
+	PublishedAt *time.Time `json:"published_at"`
+
+	// Copies of a published announcement point back at it. The server's name and icon are
+	// kept as they were when it was published, the copy is shown as coming from that server.
+	SourceMessageID     *uint64 `gorm:"index" json:"source_message_id,string,omitempty"`
+	SourceChannelID     *uint64 `json:"source_channel_id,string,omitempty"`
+	SourceServerID      *uint64 `json:"source_server_id,string,omitempty"`
+	SourceServerName    string  `gorm:"size:100" json:"source_server_name,omitempty"`
+	SourceServerIconURL string  `json:"source_server_icon_url,omitempty"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// SentBy reports whether userID wrote the message. Nobody wrote an announcement copy.
+func (m *Message) SentBy(userID uint64) bool {
+	return m.AuthorID != nil && *m.AuthorID == userID
+}
+
+// MessageRevision is what a message said before one of its edits
+type MessageRevision struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	MessageID uint64 `gorm:"not null;index" json:"message_id,string"`
+	Content   string `gorm:"type:text;not null" json:"content"`
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	// When the edit that replaced this content happened
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Emoji is a custom emoji uploaded to a server
+type Emoji struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ServerID  uint64 `gorm:"not null;index" json:"server_id,string"`
+	Name      string `gorm:"not null;size:32" json:"name"`
+	ImageURL  string `gorm:"not null" json:"image_url"`
+	CreatorID uint64 `gorm:"not null" json:"creator_id,string"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reaction is one user reacting to a message with one emoji.
+// Emoji holds the Unicode emoji itself, or the ID of a custom emoji.
+type Reaction struct {
+	MessageID uint64 `gorm:"primaryKey;autoIncrement:false" json:"message_id,string"`
+	UserID    uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"user_id,string"`
+	Emoji     string `gorm:"primaryKey;size:64" json:"emoji"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReactionEmoji is how an emoji is described to clients.
+// Unicode emoji only have a name, custom emoji also carry their ID and image.
+type ReactionEmoji struct {
+	ID       *uint64 `json:"id,string,omitempty"`
+	Name     string  `json:"name"`
+	ImageURL string  `json:"image_url,omitempty"`
+}
+
+// ReactionCount is the aggregated view of one emoji on a message
+type ReactionCount struct {
+	Emoji ReactionEmoji `json:"emoji"`
+	Count int           `json:"count"`
+	Me    bool          `json:"me"` // Whether the requesting user is one of them
+}
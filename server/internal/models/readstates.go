@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ReadState is how far a user has read in a channel
+type ReadState struct {
+	UserID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	ChannelID     uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"channel_id,string"`
+	LastMessageID uint64 `gorm:"not null;default:0" json:"last_message_id,string"` // 0 until they ack anything
+	MentionCount  int    `gorm:"not null;default:0" json:"mention_count"`
+
+	// Counted when read states are listed, not stored
+	UnreadCount int `gorm:"-" json:"unread_count"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+)
+
+// Permission is a 64-bit flag set. Bits are fixed once shipped, only append new ones.
+type Permission uint64
+
+const (
+	PermissionAdministrator   Permission = 1 << 0 // Bypasses every other check
+	PermissionViewChannels    Permission = 1 << 1
+	PermissionSendMessages    Permission = 1 << 2
+	PermissionManageMessages  Permission = 1 << 3 // Delete other people's messages
+	PermissionManageChannels  Permission = 1 << 4
+	PermissionManageServer    Permission = 1 << 5
+	PermissionManageRoles     Permission = 1 << 6
+	PermissionCreateInvite    Permission = 1 << 7
+	PermissionConnectVoice    Permission = 1 << 8
+	PermissionKickMembers     Permission = 1 << 9
+	PermissionBanMembers      Permission = 1 << 10
+	PermissionModerateMembers Permission = 1 << 11 // Time out members
+	PermissionViewAuditLog    Permission = 1 << 12
+	PermissionMentionEveryone Permission = 1 << 13 // @everyone and @here actually notify
+	PermissionBypassSlowMode  Permission = 1 << 14
+	PermissionManageWebhooks  Permission = 1 << 15 // Follow announcement channels into this server
+)
+
+// PermissionAll is every bit set, used for owners and administrators
+const PermissionAll Permission = ^Permission(0)
+
+// DefaultPermissions is what the @everyone role starts with on a new server
+const DefaultPermissions = PermissionViewChannels |
+	PermissionSendMessages |
+	PermissionCreateInvite |
+	PermissionConnectVoice
+
+// Has reports whether every bit in required is set
+func (p Permission) Has(required Permission) bool {
+	return p&required == required
+}
+
+type Role struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ServerID    uint64     `gorm:"not null;index" json:"server_id,string"`
+	Name        string     `gorm:"not null;size:100" json:"name"`
+	Color       int        `gorm:"not null;default:0" json:"color"`    // RGB packed into an int, 0 means no color
+	Position    int        `gorm:"not null;default:0" json:"position"` // Higher outranks lower, @everyone is always 0
+	Hoist       bool       `gorm:"not null;default:false" json:"hoist"`
+	Permissions Permission `gorm:"not null;default:0" json:"permissions,string"`
+	IsDefault   bool       `gorm:"not null;default:false" json:"is_default"` // The @everyone role
+
+	// Relationships
+	Server  Server       `gorm:"foreignKey:ServerID" json:"-"`
+	Members []MemberRole `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MemberRole assigns a role to a member. The @everyone role is implied and never stored here.
+type MemberRole struct {
+	ServerID uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	UserID   uint64 `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	RoleID   uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"role_id,string"`
+
+	// Relationships
+	Role Role `gorm:"foreignKey:RoleID" json:"-"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ScheduledMessage is a message queued to be sent later, once or on a repeating schedule
+type ScheduledMessage struct {
+	ID        uint64 `gorm:"primaryKey;autoIncrement:false" json:"id,string"`
+	ChannelID uint64 `gorm:"not null;index" json:"channel_id,string"`
+	AuthorID  uint64 `gorm:"not null;index" json:"author_id,string"`
+	Content   string `gorm:"type:text;not null" json:"content"`
+
+	// Cron expression (minute hour day-of-month month day-of-week), empty for a one-off
+	Recurrence string `gorm:"size:100" json:"recurrence,omitempty"`
+	Timezone   string `gorm:"size:64;not null;default:UTC" json:"timezone"` // What the recurrence is read in
+
+	// Always stored in UTC so the scheduler's comparisons line up on every database
+	NextRunAt time.Time  `gorm:"not null;index" json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Server struct {
@@ -10,24 +12,41 @@ type Server struct {
 	IconURL string `json:"icon_url"`
 	OwnerID uint64 `gorm:"not null;index" json:"owner_id,string"`
 
+	// When false, users can only join through an invite code
+	AllowDirectJoin bool `gorm:"not null;default:true" json:"allow_direct_join"`
+
+	// How many days old message versions are kept for moderators, 0 keeps them forever
+	RevisionRetentionDays int `gorm:"not null;default:30" json:"revision_retention_days"`
+
 	// Relationships
 	Owner    User           `gorm:"foreignKey:OwnerID" json:"-"`
 	Channels []Channel      `gorm:"constraint:OnDelete:CASCADE;" json:"channels,omitempty"`
 	Members  []ServerMember `gorm:"constraint:OnDelete:CASCADE;" json:"members,omitempty"`
+	Roles    []Role         `gorm:"constraint:OnDelete:CASCADE;" json:"roles,omitempty"`
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type ServerMember struct {
 	ServerID uint64 `gorm:"primaryKey;autoIncrement:false" json:"server_id,string"`
 	UserID   uint64 `gorm:"primaryKey;autoIncrement:false" json:"user_id,string"`
-	Role     string `gorm:"default:'member';not null" json:"role"`
 	Nickname string `gorm:"size:32" json:"nickname,omitempty"`
 
+	// Temporary members are removed once they go offline, or if they never connect
+	Temporary bool `gorm:"not null;default:false" json:"temporary"`
+
+	// Timed out members can read but not talk until this passes
+	TimeoutUntil *time.Time `json:"timeout_until,omitempty"`
+
+	// Filled in by handlers, never stored
+	IsOwner bool `gorm:"-" json:"is_owner"`
+
 	// Relationships
-	User   User   `gorm:"foreignKey:UserID" json:"user"`
-	Server Server `gorm:"foreignKey:ServerID" json:"-"`
+	User   User         `gorm:"foreignKey:UserID" json:"user"`
+	Server Server       `gorm:"foreignKey:ServerID" json:"-"`
+	Roles  []MemberRole `gorm:"foreignKey:ServerID,UserID;references:ServerID,UserID" json:"roles"`
 
 	JoinedAt time.Time  `gorm:"autoCreateTime" json:"joined_at"`
 	LeftAt   *time.Time `json:"left_at,omitempty"`
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// How long a thread can sit idle before it archives itself, in minutes
+var ThreadAutoArchiveDurations = []int{60, 1440, 4320, 10080}
+
+const DefaultThreadAutoArchiveDuration = 1440
+
+// ThreadMember is a user following a thread
+type ThreadMember struct {
+	ChannelID uint64 `gorm:"primaryKey;autoIncrement:false" json:"channel_id,string"`
+	UserID    uint64 `gorm:"primaryKey;autoIncrement:false;index" json:"user_id,string"`
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID" json:"user"`
+
+	JoinedAt time.Time `gorm:"autoCreateTime" json:"joined_at"`
+}
+
+// IsThread reports whether the channel was spawned from a message in another channel
+func (ch *Channel) IsThread() bool {
+	return ch.Type == ChannelTypeThread
+}
@@ -0,0 +1,203 @@
+package permissions
+
+import (
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+// ApplyOverwrites layers channel overwrites on top of server-wide permissions.
+// Order matters: @everyone first, then all of the member's roles together, then the member.
+func ApplyOverwrites(base models.Permission, everyoneRoleID uint64, roleIDs []uint64, userID uint64, overwrites []models.PermissionOverwrite) models.Permission {
+	// Administrators ignore overwrites entirely
+	if base.Has(models.PermissionAdministrator) {
+		return models.PermissionAll
+	}
+
+	perms := base
+
+	held := make(map[uint64]bool, len(roleIDs))
+	for _, id := range roleIDs {
+		held[id] = true
+	}
+
+	var everyone, member *models.PermissionOverwrite
+	var roleAllow, roleDeny models.Permission
+	for i := range overwrites {
+		ow := &overwrites[i]
+		switch {
+		case ow.Type == models.OverwriteTypeRole && ow.TargetID == everyoneRoleID:
+			everyone = ow
+		case ow.Type == models.OverwriteTypeRole && held[ow.TargetID]:
+			roleAllow |= ow.Allow
+			roleDeny |= ow.Deny
+		case ow.Type == models.OverwriteTypeMember && ow.TargetID == userID:
+			member = ow
+		}
+	}
+
+	if everyone != nil {
+		perms &^= everyone.Deny
+		perms |= everyone.Allow
+	}
+
+	perms &^= roleDeny
+	perms |= roleAllow
+
+	if member != nil {
+		perms &^= member.Deny
+		perms |= member.Allow
+	}
+
+	// A channel you can't see grants nothing else
+	if !perms.Has(models.PermissionViewChannels) {
+		return 0
+	}
+	// Only roles make someone an administrator, never an overwrite
+	return perms &^ models.PermissionAdministrator
+}
+
+// EveryoneRoleID picks the @everyone role out of a resolved member's roles
+func (m *Member) EveryoneRoleID() uint64 {
+	for _, role := range m.Roles {
+		if role.IsDefault {
+			return role.ID
+		}
+	}
+	return 0
+}
+
+// RoleIDs lists the IDs of every role the member holds, including @everyone
+func (m *Member) RoleIDs() []uint64 {
+	ids := make([]uint64, 0, len(m.Roles))
+	for _, role := range m.Roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+// InChannel computes the member's permissions for a channel given its overwrites
+func (m *Member) InChannel(overwrites []models.PermissionOverwrite) models.Permission {
+	if m.IsOwner {
+		return models.PermissionAll
+	}
+	// Overwrites can't hand a timed out member their voice back
+	return m.applyTimeout(ApplyOverwrites(m.Permissions, m.EveryoneRoleID(), m.RoleIDs(), m.UserID, overwrites))
+}
+
+// ChannelOverwrites loads the overwrites that apply to a channel.
+// Threads have none of their own, they follow their parent channel.
+// Channels in a category keep their own, the category's only cover the category itself.
+func ChannelOverwrites(channelID uint64) ([]models.PermissionOverwrite, error) {
+	var channel models.Channel
+	if err := database.DB.Select("id", "type", "parent_id").First(&channel, channelID).Error; err != nil {
+		return nil, err
+	}
+	if channel.IsThread() {
+		channelID = *channel.ParentID
+	}
+
+	var overwrites []models.PermissionOverwrite
+	err := database.DB.Where("channel_id = ?", channelID).Find(&overwrites).Error
+	return overwrites, err
+}
+
+// ForChannel loads a channel's overwrites and computes the member's permissions in it
+func (m *Member) ForChannel(channelID uint64) (models.Permission, error) {
+	overwrites, err := ChannelOverwrites(channelID)
+	if err != nil {
+		return 0, err
+	}
+	return m.InChannel(overwrites), nil
+}
+
+// ChannelViewers returns the set of users allowed to see a channel.
+// A nil set means every active member of the server can see it.
+func ChannelViewers(serverID uint64, channelID uint64) (map[uint64]bool, error) {
+	overwrites, err := ChannelOverwrites(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []models.Role
+	if err := database.DB.Where("server_id = ?", serverID).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	rolesByID := make(map[uint64]models.Role, len(roles))
+	var everyone models.Role
+	for _, role := range roles {
+		rolesByID[role.ID] = role
+		if role.IsDefault {
+			everyone = role
+		}
+	}
+
+	// Fast path for the common case of an open channel
+	if len(overwrites) == 0 && everyone.Permissions.Has(models.PermissionViewChannels) {
+		return nil, nil
+	}
+
+	var server models.Server
+	if err := database.DB.Select("id", "owner_id").First(&server, serverID).Error; err != nil {
+		return nil, err
+	}
+
+	var members []models.ServerMember
+	if err := database.DB.Preload("Roles").
+		Where("server_id = ? AND left_at IS NULL", serverID).
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	viewers := make(map[uint64]bool)
+	for _, sm := range members {
+		member := &Member{
+			ServerID: serverID,
+			UserID:   sm.UserID,
+			IsOwner:  sm.UserID == server.OwnerID,
+			Roles:    []models.Role{everyone},
+		}
+		for _, assigned := range sm.Roles {
+			if role, ok := rolesByID[assigned.RoleID]; ok {
+				member.Roles = append(member.Roles, role)
+			}
+		}
+		member.Permissions = ComputeBase(member.IsOwner, member.Roles)
+
+		if member.InChannel(overwrites).Has(models.PermissionViewChannels) {
+			viewers[sm.UserID] = true
+		}
+	}
+
+	return viewers, nil
+}
+
+// VisibleChannelIDs returns every channel in the server the member can see,
+// including threads under the channels they can see
+func (m *Member) VisibleChannelIDs() ([]uint64, error) {
+	var channels []models.Channel
+	if err := database.DB.Preload("Overwrites").
+		Where("server_id = ? AND type <> ?", m.ServerID, models.ChannelTypeThread).
+		Find(&channels).Error; err != nil {
+		return nil, err
+	}
+
+	var visible []uint64
+	for _, channel := range channels {
+		if m.InChannel(channel.Overwrites).Has(models.PermissionViewChannels) {
+			visible = append(visible, channel.ID)
+		}
+	}
+	if len(visible) == 0 {
+		return visible, nil
+	}
+
+	var threadIDs []uint64
+	if err := database.DB.Model(&models.Channel{}).
+		Where("type = ? AND parent_id IN ?", models.ChannelTypeThread, visible).
+		Pluck("id", &threadIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return append(visible, threadIDs...), nil
+}
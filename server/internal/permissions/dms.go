@@ -0,0 +1,24 @@
+package permissions
+
+import (
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+// DMRecipients lists the users taking part in a DM or group DM channel
+func DMRecipients(channelID uint64) ([]uint64, error) {
+	var userIDs []uint64
+	err := database.DB.Model(&models.DMRecipient{}).
+		Where("channel_id = ?", channelID).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// IsDMRecipient reports whether the user is part of a DM or group DM channel
+func IsDMRecipient(channelID uint64, userID uint64) bool {
+	var count int64
+	database.DB.Model(&models.DMRecipient{}).
+		Where("channel_id = ? AND user_id = ?", channelID, userID).
+		Count(&count)
+	return count > 0
+}
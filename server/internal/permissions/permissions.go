@@ -0,0 +1,116 @@
+package permissions
+
+import (
+	"math"
+	"time"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+// Member is a resolved view of one user's standing in one server
+type Member struct {
+	ServerID    uint64
+	UserID      uint64
+	IsOwner     bool
+	TimedOut    bool
+	Roles       []models.Role // Includes the @everyone role
+	Permissions models.Permission
+}
+
+// Resolve loads an active member and computes their server-wide permissions.
+// Returns an error if the user is not an active member of the server.
+func Resolve(serverID uint64, userID uint64) (*Member, error) {
+	var membership models.ServerMember
+	if err := database.DB.Where("server_id = ? AND user_id = ? AND left_at IS NULL", serverID, userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+
+	var server models.Server
+	if err := database.DB.Select("id", "owner_id").First(&server, serverID).Error; err != nil {
+		return nil, err
+	}
+
+	// The @everyone role plus every role assigned to this member
+	var roles []models.Role
+	if err := database.DB.
+		Where("server_id = ? AND (is_default = ? OR id IN (?))", serverID, true,
+			database.DB.Model(&models.MemberRole{}).Select("role_id").Where("server_id = ? AND user_id = ?", serverID, userID)).
+		Order("position asc").
+		Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	member := &Member{
+		ServerID: serverID,
+		UserID:   userID,
+		IsOwner:  server.OwnerID == userID,
+		TimedOut: membership.TimeoutUntil != nil && time.Now().Before(*membership.TimeoutUntil),
+		Roles:    roles,
+	}
+	member.Permissions = ComputeBase(member.IsOwner, roles)
+	member.Permissions = member.applyTimeout(member.Permissions)
+
+	return member, nil
+}
+
+// ComputeBase folds a set of roles into one permission set
+func ComputeBase(isOwner bool, roles []models.Role) models.Permission {
+	// Owners can do absolutely anything
+	if isOwner {
+		return models.PermissionAll
+	}
+
+	var perms models.Permission
+	for _, role := range roles {
+		perms |= role.Permissions
+	}
+
+	if perms.Has(models.PermissionAdministrator) {
+		return models.PermissionAll
+	}
+	return perms
+}
+
+// Timed out members keep read access and lose everything else.
+// Owners and administrators can't be silenced this way. That is decided by the
+// server-wide permissions, whatever a channel's overwrites say.
+func (m *Member) applyTimeout(perms models.Permission) models.Permission {
+	if !m.TimedOut || m.Permissions.Has(models.PermissionAdministrator) {
+		return perms
+	}
+	return perms & models.PermissionViewChannels
+}
+
+// Has reports whether the member holds the required permission server-wide
+func (m *Member) Has(required models.Permission) bool {
+	return m.Permissions.Has(required)
+}
+
+// HighestPosition is the rank of the member's top role. Owners outrank everything.
+func (m *Member) HighestPosition() int {
+	if m.IsOwner {
+		return math.MaxInt
+	}
+
+	highest := 0
+	for _, role := range m.Roles {
+		if role.Position > highest {
+			highest = role.Position
+		}
+	}
+	return highest
+}
+
+// CanManageRole reports whether the role sits strictly below the member's top role
+func (m *Member) CanManageRole(role models.Role) bool {
+	return role.Position < m.HighestPosition()
+}
+
+// Outranks reports whether the member sits strictly above another member in the hierarchy
+func (m *Member) Outranks(other *Member) bool {
+	if other.IsOwner {
+		return false
+	}
+	return m.HighestPosition() > other.HighestPosition()
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/config"
+)
+
+// Idle entries are dropped at most this often so the maps don't grow forever
+const sweepInterval = time.Minute
+
+// Limiter is a token bucket per key. Each key can spend up to burst tokens at
+// once, and gets them back at a steady rate.
+type Limiter struct {
+	rate  float64 // Tokens per second
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func NewLimiter(perMinute int, burst int) *Limiter {
+	return &Limiter{
+		rate:      float64(perMinute) / 60,
+		burst:     float64(burst),
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow spends a token for key. When there are none left it says how long until the next one.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst}
+		l.buckets[key] = b
+	} else {
+		b.tokens = min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// A bucket that has refilled completely is the same as no bucket
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Cooldown makes a key wait a set time between actions, used for slow mode
+type Cooldown struct {
+	mu        sync.Mutex
+	until     map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewCooldown() *Cooldown {
+	return &Cooldown{until: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// Take starts a cooldown of length d for key, unless one is still running.
+// Checking and starting happen together so two requests can't both get through.
+func (c *Cooldown) Take(key string, d time.Duration) (bool, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= sweepInterval {
+		c.lastSweep = now
+		for k, until := range c.until {
+			if now.After(until) {
+				delete(c.until, k)
+			}
+		}
+	}
+
+	if until, ok := c.until[key]; ok && now.Before(until) {
+		return false, until.Sub(now)
+	}
+	c.until[key] = now.Add(d)
+	return true, 0
+}
+
+// Clear ends a cooldown early, for when the action it was taken for didn't happen
+func (c *Cooldown) Clear(key string) {
+	c.mu.Lock()
+	delete(c.until, key)
+	c.mu.Unlock()
+}
+
+// The limits shared by the HTTP handlers and the websocket router. Set by Init.
+var (
+	Messages *Limiter // Messages sent, per user
+	Events   *Limiter // Websocket events, per user, kept apart so typing doesn't eat into messages
+	SlowMode = NewCooldown()
+)
+
+// Init sets up the per user limits from the config
+func Init(cfg *config.Config) {
+	Messages = NewLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+	Events = NewLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+}
@@ -0,0 +1,128 @@
+package readstates
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+// Ack moves a user's read marker in a channel to messageID. The marker can move
+// backwards too, which is how "mark unread" works, so the mention count is
+// recounted from whatever is left after it. Callers check access first.
+func Ack(userID uint64, channelID uint64, messageID uint64) (models.ReadState, error) {
+	state := models.ReadState{UserID: userID, ChannelID: channelID, LastMessageID: messageID}
+
+	var err error
+	state.MentionCount, err = countMentions(userID, channelID, messageID)
+	if err != nil {
+		return state, err
+	}
+	state.UpdatedAt = time.Now()
+
+	var unread int64
+	if err := database.DB.Model(&models.Message{}).
+		Where("channel_id = ? AND id > ? AND (author_id IS NULL OR author_id <> ?)", channelID, messageID, userID).
+		Count(&unread).Error; err != nil {
+		return state, err
+	}
+	state.UnreadCount = int(unread)
+
+	err = database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_message_id", "mention_count", "updated_at"}),
+	}).Create(&state).Error
+	return state, err
+}
+
+// AddMention bumps the mention badge in a channel for each user
+func AddMention(channelID uint64, userIDs []uint64) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	states := make([]models.ReadState, len(userIDs))
+	for i, userID := range userIDs {
+		states[i] = models.ReadState{UserID: userID, ChannelID: channelID, MentionCount: 1}
+	}
+
+	// People who never opened the channel get a fresh read state at message 0
+	return database.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"mention_count": gorm.Expr("read_states.mention_count + 1"),
+			"updated_at":    time.Now(),
+		}),
+	}).Create(&states).Error
+}
+
+// ForChannels loads a user's read states for a set of channels along with how many
+// messages from other people are past each marker. Channels they've never read
+// come back with a zero marker so everything in them counts as unread.
+func ForChannels(userID uint64, channelIDs []uint64) (map[uint64]*models.ReadState, error) {
+	states := make(map[uint64]*models.ReadState, len(channelIDs))
+	if len(channelIDs) == 0 {
+		return states, nil
+	}
+
+	var stored []models.ReadState
+	if err := database.DB.Where("user_id = ? AND channel_id IN ?", userID, channelIDs).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for i := range stored {
+		states[stored[i].ChannelID] = &stored[i]
+	}
+	for _, channelID := range channelIDs {
+		if states[channelID] == nil {
+			states[channelID] = &models.ReadState{UserID: userID, ChannelID: channelID}
+		}
+	}
+
+	// One grouped count for every channel at once
+	var counts []struct {
+		ChannelID uint64
+		Count     int
+	}
+	if err := database.DB.Model(&models.Message{}).
+		Select("messages.channel_id, COUNT(*) AS count").
+		Joins("LEFT JOIN read_states ON read_states.channel_id = messages.channel_id AND read_states.user_id = ?", userID).
+		Where("messages.channel_id IN ? AND (messages.author_id IS NULL OR messages.author_id <> ?)", channelIDs, userID).
+		Where("messages.id > COALESCE(read_states.last_message_id, 0)").
+		Group("messages.channel_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		states[count.ChannelID].UnreadCount = count.Count
+	}
+
+	return states, nil
+}
+
+// Counts messages after a marker that ping the user, directly, through one of
+// their roles or with @everyone/@here
+func countMentions(userID uint64, channelID uint64, afterID uint64) (int, error) {
+	var channel models.Channel
+	if err := database.DB.Select("id", "server_id").First(&channel, channelID).Error; err != nil {
+		return 0, err
+	}
+
+	direct := database.DB.Model(&models.MessageMention{}).Select("message_id").Where("user_id = ?", userID)
+	pinged := database.DB.Where("messages.id IN (?)", direct)
+
+	if channel.ServerID != nil {
+		roles := database.DB.Model(&models.MemberRole{}).Select("role_id").Where("server_id = ? AND user_id = ?", *channel.ServerID, userID)
+		byRole := database.DB.Model(&models.MessageRoleMention{}).Select("message_id").Where("role_id IN (?)", roles)
+		pinged = pinged.Or("messages.id IN (?)", byRole).Or("messages.mention_everyone = ?", true)
+	}
+
+	var count int64
+	err := database.DB.Model(&models.Message{}).
+		Where("messages.channel_id = ? AND messages.id > ? AND (messages.author_id IS NULL OR messages.author_id <> ?)", channelID, afterID, userID).
+		Where(pinged).
+		Count(&count).Error
+	return int(count), err
+}
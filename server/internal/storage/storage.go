@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/config"
+)
+
+// ErrNotFound is returned by drivers when a key doesn't exist
+var ErrNotFound = errors.New("object not found")
+
+// Driver is a place uploaded files can be kept
+type Driver interface {
+	// Put stores size bytes from r under key, replacing anything already there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object under key. Missing objects are not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Store is the configured driver. Set by Init.
+var Store Driver
+
+// Upload limits in bytes, per file and per server. Set by Init.
+var (
+	MaxFileSize       int64
+	ServerUploadQuota int64
+)
+
+// Base URL clients reach the server on, public links to stored images start with it. Set by Init.
+var PublicURL string
+
+// Init picks the storage driver from the config
+func Init(cfg *config.Config) error {
+	PublicURL = cfg.PublicURL
+	MaxFileSize = cfg.MaxUploadSize
+	ServerUploadQuota = cfg.ServerUploadQuota
+
+	switch cfg.StorageDriver {
+	case "local", "":
+		driver, err := NewLocal(cfg.StoragePath)
+		if err != nil {
+			return err
+		}
+		Store = driver
+	case "s3":
+		driver, err := NewS3(cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3Region, cfg.S3UseSSL)
+		if err != nil {
+			return err
+		}
+		Store = driver
+	default:
+		return fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+	return nil
+}
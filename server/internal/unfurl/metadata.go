@@ -0,0 +1,259 @@
+package unfurl
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const (
+	maxTitleLength       = 256
+	maxDescriptionLength = 1024
+	maxNameLength        = 256
+)
+
+// metadata is everything a page says about itself, before it's turned into an embed
+type metadata struct {
+	title       string
+	description string
+	siteName    string
+	authorName  string
+	kind        string // og:type or the oEmbed type
+	image       *models.EmbedMedia
+	video       *models.EmbedMedia
+	oEmbedURL   string
+}
+
+// Reads the <head> of a page for OpenGraph, Twitter card and plain HTML metadata.
+// OpenGraph wins over the others when a page has more than one.
+func parseHTML(r io.Reader, base *url.URL) metadata {
+	var meta metadata
+	var fallbackTitle, fallbackDescription, twitterImage string
+	var imageWidth, imageHeight, videoWidth, videoHeight int
+	var imageURL, videoURL string
+
+	tokens := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokens.Next() {
+		case html.ErrorToken:
+			goto done
+
+		case html.TextToken:
+			if inTitle && fallbackTitle == "" {
+				fallbackTitle = strings.TrimSpace(string(tokens.Text()))
+			}
+
+		case html.EndTagToken:
+			name, _ := tokens.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				goto done
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttrs := tokens.TagName()
+			attrs := map[string]string{}
+			for hasAttrs {
+				var key, value []byte
+				key, value, hasAttrs = tokens.TagAttr()
+				attrs[strings.ToLower(string(key))] = string(value)
+			}
+
+			switch string(name) {
+			case "body":
+				// Metadata only lives in the head, no need to read the rest of the page
+				goto done
+			case "title":
+				inTitle = true
+			case "link":
+				if strings.EqualFold(attrs["rel"], "alternate") && attrs["type"] == "application/json+oembed" {
+					meta.oEmbedURL = resolve(base, attrs["href"])
+				}
+			case "meta":
+				key := attrs["property"]
+				if key == "" {
+					key = attrs["name"]
+				}
+				value := strings.TrimSpace(attrs["content"])
+				switch strings.ToLower(key) {
+				case "og:title":
+					meta.title = value
+				case "og:description":
+					meta.description = value
+				case "og:site_name":
+					meta.siteName = value
+				case "og:type":
+					meta.kind = value
+				case "og:image", "og:image:url", "og:image:secure_url":
+					if imageURL == "" {
+						imageURL = resolve(base, value)
+					}
+				case "og:image:width":
+					imageWidth, _ = strconv.Atoi(value)
+				case "og:image:height":
+					imageHeight, _ = strconv.Atoi(value)
+				case "og:video", "og:video:url", "og:video:secure_url":
+					if videoURL == "" {
+						videoURL = resolve(base, value)
+					}
+				case "og:video:width":
+					videoWidth, _ = strconv.Atoi(value)
+				case "og:video:height":
+					videoHeight, _ = strconv.Atoi(value)
+				case "twitter:title":
+					if fallbackTitle == "" {
+						fallbackTitle = value
+					}
+				case "twitter:description", "description":
+					if fallbackDescription == "" {
+						fallbackDescription = value
+					}
+				case "twitter:image", "twitter:image:src":
+					twitterImage = resolve(base, value)
+				case "author":
+					meta.authorName = value
+				}
+			}
+		}
+	}
+
+done:
+	if meta.title == "" {
+		meta.title = fallbackTitle
+	}
+	if meta.description == "" {
+		meta.description = fallbackDescription
+	}
+	if imageURL == "" {
+		imageURL = twitterImage
+	}
+	if imageURL != "" {
+		meta.image = &models.EmbedMedia{URL: imageURL, Width: imageWidth, Height: imageHeight}
+	}
+	if videoURL != "" {
+		meta.video = &models.EmbedMedia{URL: videoURL, Width: videoWidth, Height: videoHeight}
+	}
+	return meta
+}
+
+// The parts of an oEmbed response we use, see https://oembed.com
+type oEmbedResponse struct {
+	Type            string `json:"type"`
+	Title           string `json:"title"`
+	AuthorName      string `json:"author_name"`
+	ProviderName    string `json:"provider_name"`
+	URL             string `json:"url"` // The image itself for photos
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+}
+
+func (u *Unfurler) fetchOEmbed(ctx context.Context, rawURL string) (metadata, error) {
+	resp, err := u.get(ctx, rawURL, "application/json")
+	if err != nil {
+		return metadata{}, err
+	}
+	defer resp.Body.Close()
+
+	var data oEmbedResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOEmbed)).Decode(&data); err != nil {
+		return metadata{}, err
+	}
+
+	base := resp.Request.URL
+	meta := metadata{
+		title:      data.Title,
+		authorName: data.AuthorName,
+		siteName:   data.ProviderName,
+		kind:       data.Type,
+	}
+	if data.Type == "photo" && data.URL != "" {
+		meta.image = &models.EmbedMedia{URL: resolve(base, data.URL), Width: data.Width, Height: data.Height}
+	} else if data.ThumbnailURL != "" {
+		meta.image = &models.EmbedMedia{URL: resolve(base, data.ThumbnailURL), Width: data.ThumbnailWidth, Height: data.ThumbnailHeight}
+	}
+	return meta, nil
+}
+
+// Fills in whatever the page itself left out from its oEmbed data
+func (m *metadata) merge(other metadata) {
+	if m.title == "" {
+		m.title = other.title
+	}
+	if m.authorName == "" {
+		m.authorName = other.authorName
+	}
+	if m.siteName == "" {
+		m.siteName = other.siteName
+	}
+	if m.image == nil {
+		m.image = other.image
+	}
+	// oEmbed says what the content is more reliably than og:type
+	if other.kind != "" {
+		m.kind = other.kind
+	}
+}
+
+// Turns the metadata into an embed, or nil when there's nothing worth showing
+func (m metadata) embed(rawURL string) *models.Embed {
+	if m.title == "" && m.description == "" && m.image == nil {
+		return nil
+	}
+
+	embed := &models.Embed{
+		URL:         rawURL,
+		Type:        "link",
+		Title:       truncate(m.title, maxTitleLength),
+		Description: truncate(m.description, maxDescriptionLength),
+		SiteName:    truncate(m.siteName, maxNameLength),
+		AuthorName:  truncate(m.authorName, maxNameLength),
+		Image:       m.image,
+		Video:       m.video,
+	}
+	switch {
+	case m.kind == "photo":
+		embed.Type = "image"
+	case m.kind == "rich":
+		embed.Type = "rich"
+	case m.video != nil || m.kind == "video" || strings.HasPrefix(m.kind, "video."):
+		embed.Type = "video"
+	}
+	return embed
+}
+
+// Makes a link from the page absolute, anything that isn't http(s) is dropped
+func resolve(base *url.URL, raw string) string {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || raw == "" {
+		return ""
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+	return resolved.String()
+}
+
+// Cuts a string to at most max characters, marking that it was cut
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
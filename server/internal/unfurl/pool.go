@@ -0,0 +1,164 @@
+package unfurl
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/config"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const (
+	MaxURLsPerMessage = 5
+	queueSize         = 256
+	cacheTTL          = 30 * time.Minute
+	maxCacheEntries   = 1000
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+// FindURLs pulls the links worth previewing out of message content, in order and without repeats.
+// Links wrapped in <angle brackets> are left alone, that's how people opt out of a preview.
+func FindURLs(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, match := range urlPattern.FindAllStringIndex(content, -1) {
+		start, end := match[0], match[1]
+		if start > 0 && content[start-1] == '<' && end < len(content) && content[end] == '>' {
+			continue
+		}
+
+		link := trimTrailing(content[start:end])
+		if seen[link] {
+			continue
+		}
+		seen[link] = true
+		urls = append(urls, link)
+		if len(urls) == MaxURLsPerMessage {
+			break
+		}
+	}
+	return urls
+}
+
+// Drops sentence punctuation that got caught on the end of a link. A closing
+// paren only goes if it isn't balancing one inside the link, like on Wikipedia.
+func trimTrailing(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		if last == ')' && strings.Count(link, "(") >= strings.Count(link, ")") {
+			break
+		}
+		if !strings.ContainsRune(".,;:!?)]}*_~", rune(last)) {
+			break
+		}
+		link = link[:len(link)-1]
+	}
+	return link
+}
+
+// Job is one message's links. Done gets whatever previews could be built, in link order.
+type Job struct {
+	URLs []string
+	Done func(embeds []models.Embed)
+}
+
+// Pool fetches previews on a fixed number of workers so a burst of links can't open unbounded connections
+type Pool struct {
+	unfurler *Unfurler
+	jobs     chan Job
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	embed   *models.Embed // nil when the link had nothing to preview
+	expires time.Time
+}
+
+func NewPool(unfurler *Unfurler, workers int) *Pool {
+	p := &Pool{
+		unfurler: unfurler,
+		jobs:     make(chan Job, queueSize),
+		cache:    make(map[string]cacheEntry),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Default is the pool messages use, nil when previews are turned off. Set by Init.
+var Default *Pool
+
+// Init starts the preview workers from the config
+func Init(cfg *config.Config) {
+	if cfg.UnfurlWorkers <= 0 {
+		log.Println("Link previews are disabled")
+		return
+	}
+	Default = NewPool(New(), cfg.UnfurlWorkers)
+}
+
+// Submit queues a job without blocking. When the queue is full the previews are
+// skipped, the message itself is already out so nobody is left waiting.
+func (p *Pool) Submit(job Job) bool {
+	select {
+	case p.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Pool) work() {
+	for job := range p.jobs {
+		var embeds []models.Embed
+		for _, link := range job.URLs {
+			if embed := p.unfurl(link); embed != nil {
+				embeds = append(embeds, *embed)
+			}
+		}
+		job.Done(embeds)
+	}
+}
+
+// Serves a link from the cache when it can. Failed fetches aren't cached, they may just be a blip.
+func (p *Pool) unfurl(link string) *models.Embed {
+	p.mu.Lock()
+	entry, ok := p.cache[link]
+	p.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.embed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*fetchTimeout)
+	defer cancel()
+
+	embed, err := p.unfurler.Unfurl(ctx, link)
+	if err != nil && err != ErrUnsupported {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.cache) >= maxCacheEntries {
+		now := time.Now()
+		for key, cached := range p.cache {
+			if now.After(cached.expires) {
+				delete(p.cache, key)
+			}
+		}
+		// Still full of live entries, start over rather than track usage
+		if len(p.cache) >= maxCacheEntries {
+			p.cache = make(map[string]cacheEntry)
+		}
+	}
+	p.cache[link] = cacheEntry{embed: embed, expires: time.Now().Add(cacheTTL)}
+	return embed
+}
@@ -0,0 +1,180 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+)
+
+const (
+	fetchTimeout = 5 * time.Second
+	maxPageSize  = 1 << 20  // Only the head of a page matters, this is plenty
+	maxOEmbed    = 64 << 10 // oEmbed responses are tiny
+	maxRedirects = 5
+	userAgent    = "Mozilla/5.0 (compatible; HermesBot/1.0; link previews)"
+)
+
+var (
+	ErrBlockedAddress = errors.New("address is not allowed")
+	ErrUnsupported    = errors.New("nothing to preview")
+)
+
+// Unfurler fetches link previews. Every connection is checked after DNS
+// resolution, so redirects and rebinding can't reach internal addresses.
+type Unfurler struct {
+	Client *http.Client
+
+	// Decides which resolved addresses can be dialed. Always isPublicIP outside of
+	// tests, which swap it so they can reach an httptest server on loopback.
+	allowIP func(net.IP) bool
+}
+
+func New() *Unfurler {
+	u := &Unfurler{allowIP: isPublicIP}
+
+	dialer := &net.Dialer{
+		Timeout: fetchTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !u.allowIP(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	u.Client = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			// No proxy, it would do the dialing and skip the address check
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   fetchTimeout,
+			ResponseHeaderTimeout: fetchTimeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+	return u
+}
+
+// Only addresses on the public internet can be fetched
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, block := range reservedBlocks {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Ranges the net package doesn't flag but that still aren't the public internet
+var reservedBlocks = func() []*net.IPNet {
+	var blocks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // This network
+		"100.64.0.0/10", // Carrier grade NAT
+		"192.0.0.0/24",  // Protocol assignments
+		"198.18.0.0/15", // Benchmarking
+		"240.0.0.0/4",   // Reserved
+		"64:ff9b::/96",  // NAT64, can wrap a private IPv4 address
+	} {
+		_, block, _ := net.ParseCIDR(cidr)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}()
+
+// Unfurl builds a preview for one link. Pages get their OpenGraph and oEmbed
+// metadata read, direct image links become an image embed.
+func (u *Unfurler) Unfurl(ctx context.Context, rawURL string) (*models.Embed, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, ErrUnsupported
+	}
+
+	resp, err := u.get(ctx, target.String(), "text/html,application/xhtml+xml,image/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := io.LimitReader(resp.Body, maxPageSize)
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"):
+		embed := &models.Embed{URL: rawURL, Type: "image", Image: &models.EmbedMedia{URL: rawURL}}
+		if config, _, err := image.DecodeConfig(body); err == nil {
+			embed.Image.Width, embed.Image.Height = config.Width, config.Height
+		}
+		return embed, nil
+
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		// Relative links on the page resolve against wherever the redirects ended up
+		meta := parseHTML(body, resp.Request.URL)
+		if meta.oEmbedURL != "" {
+			if oembed, err := u.fetchOEmbed(ctx, meta.oEmbedURL); err == nil {
+				meta.merge(oembed)
+			}
+		}
+		embed := meta.embed(rawURL)
+		if embed == nil {
+			return nil, ErrUnsupported
+		}
+		return embed, nil
+	}
+
+	return nil, ErrUnsupported
+}
+
+func (u *Unfurler) get(ctx context.Context, rawURL string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
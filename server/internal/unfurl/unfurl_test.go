@@ -0,0 +1,112 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"8.8.8.8", true},
+		{"1.1.1.1", true},
+		{"2606:4700:4700::1111", true},
+
+		{"127.0.0.1", false},       // Loopback
+		{"127.10.0.1", false},      // Loopback, not just .1
+		{"::1", false},             // IPv6 loopback
+		{"0.0.0.0", false},         // Unspecified
+		{"::", false},              // IPv6 unspecified
+		{"10.0.0.1", false},        // RFC 1918
+		{"172.16.5.4", false},      // RFC 1918
+		{"172.31.255.255", false},  // RFC 1918, top of the range
+		{"192.168.1.1", false},     // RFC 1918
+		{"169.254.169.254", false}, // Link local, cloud metadata
+		{"169.254.0.1", false},     // Link local
+		{"fe80::1", false},         // IPv6 link local
+		{"fc00::1", false},         // Unique local
+		{"fd00:ec2::254", false},   // Unique local, cloud metadata over IPv6
+		{"224.0.0.1", false},       // Multicast
+		{"ff02::1", false},         // IPv6 multicast
+
+		// IPv4 wrapped in IPv6 is still the IPv4 address underneath
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:8.8.8.8", true},
+
+		// The reserved blocks the net package doesn't know about
+		{"0.1.2.3", false},         // This network
+		{"100.64.0.1", false},      // Carrier grade NAT
+		{"100.127.255.255", false}, // Carrier grade NAT, top of the range
+		{"192.0.0.8", false},       // Protocol assignments
+		{"198.18.0.1", false},      // Benchmarking
+		{"198.19.255.255", false},  // Benchmarking, top of the range
+		{"240.0.0.1", false},       // Reserved
+		{"255.255.255.255", false}, // Broadcast
+		{"64:ff9b::a00:1", false},  // NAT64 wrapping 10.0.0.1
+		{"64:ff9b::7f00:1", false}, // NAT64 wrapping 127.0.0.1
+
+		// Just outside the reserved blocks
+		{"100.63.255.255", true},
+		{"100.128.0.0", true},
+		{"198.17.255.255", true},
+		{"198.20.0.0", true},
+	}
+
+	for _, tt := range tests {
+		ip := net.ParseIP(tt.ip)
+		if ip == nil {
+			t.Fatalf("bad test address %q", tt.ip)
+		}
+		if got := isPublicIP(ip); got != tt.public {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+func TestUnfurlRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := New().Unfurl(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("got %v, want ErrBlockedAddress", err)
+	}
+}
+
+func TestUnfurlRefusesRedirectToPrivateAddress(t *testing.T) {
+	targets := []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/",
+		"http://[::1]/",
+		"http://[::ffff:169.254.169.254]/",
+	}
+
+	for _, target := range targets {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, target, http.StatusFound)
+		}))
+
+		// Only the test server itself is let through, the redirect has to pass the real check
+		serverIP := net.ParseIP(server.Listener.Addr().(*net.TCPAddr).IP.String())
+		u := New()
+		u.allowIP = func(ip net.IP) bool {
+			return ip.Equal(serverIP) || isPublicIP(ip)
+		}
+
+		_, err := u.Unfurl(context.Background(), server.URL)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("redirect to %s: got %v, want ErrBlockedAddress", target, err)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const inviteAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// GenerateInviteCode returns a random base62 code of the given length
+func GenerateInviteCode(length int) (string, error) {
+	code := make([]byte, length)
+	max := big.NewInt(int64(len(inviteAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -18,11 +18,44 @@ var upgrader = websocket.Upgrader{
 
 // VoiceClient represents a 1-to-1 WebRTC signaling connection
 type VoiceClient struct {
-	Conn            *websocket.Conn
-	UserID          uint64
-	ActiveChannelID uint64
-	ActiveServerID  uint64
-	Send            chan websockets.WsMessage
+	Conn   *websocket.Conn
+	UserID uint64
+	Send   chan websockets.WsMessage
+
+	// The voice channel they're in. Moderation clears it from HTTP handlers
+	// while the read pump uses it, so it's only touched through the methods below.
+	mu              sync.Mutex
+	activeChannelID uint64
+	activeServerID  uint64
+}
+
+// ActiveChannel is the voice channel the client is connected to, 0 if none
+func (c *VoiceClient) ActiveChannel() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.activeChannelID
+}
+
+func (c *VoiceClient) setActive(channelID uint64, serverID uint64) {
+	c.mu.Lock()
+	c.activeChannelID = channelID
+	c.activeServerID = serverID
+	c.mu.Unlock()
+}
+
+// Clears the active channel and returns what it was. With serverID set, only a channel in
+// that server is cleared. Whoever gets a channel back is the one that announces the leave.
+func (c *VoiceClient) takeActive(serverID uint64) (uint64, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if serverID != 0 && c.activeServerID != serverID {
+		return 0, 0
+	}
+	channelID, activeServerID := c.activeChannelID, c.activeServerID
+	c.activeChannelID = 0
+	c.activeServerID = 0
+	return channelID, activeServerID
 }
 
 // VoiceRegistry safely holds all active signaling connections
@@ -74,39 +107,7 @@ func (c *VoiceClient) readPump() {
 		VoiceRegistry.Unlock()
 		c.Conn.Close()
 
-		// Broadcast user leave
-		if c.ActiveChannelID != 0 {
-			websockets.Manager.Broadcast <- websockets.WsMessage{
-				TargetServerID: c.ActiveServerID,
-				Event:          "VOICE_STATE_UPDATE",
-				Data: map[string]interface{}{
-					"channel_id": c.ActiveChannelID,
-					"action":     "leave",
-					"user_id":    c.UserID,
-				},
-			}
-		}
-
-		// Gather the rooms safely WITHOUT calling RemovePeer yet
-		var activeRooms []*Room
-
-		Manager.mu.RLock()
-		for _, room := range Manager.Rooms {
-			room.mu.RLock()
-			_, exists := room.Peers[c.UserID]
-			room.mu.RUnlock()
-
-			if exists {
-				activeRooms = append(activeRooms, room)
-			}
-		}
-		Manager.mu.RUnlock() // Release the Manager lock entirely
-
-		// Now it is safe to remove the peer
-		for _, room := range activeRooms {
-			log.Printf("[Voice WS] Removing User %d from Room %d", c.UserID, room.ID)
-			room.RemovePeer(c.UserID)
-		}
+		c.leaveActiveChannel()
 	}()
 
 	log.Printf("[Voice WS] Started read pump for User %d", c.UserID)
@@ -132,3 +133,75 @@ func (c *VoiceClient) writePump() {
 		}
 	}
 }
+
+// Broadcasts the leave and tears down this client's peer in every room it joined
+func (c *VoiceClient) leaveActiveChannel() {
+	channelID, serverID := c.takeActive(0)
+	c.leave(channelID, serverID)
+}
+
+func (c *VoiceClient) leave(channelID uint64, serverID uint64) {
+	// Broadcast user leave
+	if channelID != 0 {
+		websockets.Manager.Broadcast <- websockets.WsMessage{
+			TargetServerID: serverID,
+			Event:          "VOICE_STATE_UPDATE",
+			Data: map[string]interface{}{
+				"channel_id": channelID,
+				"action":     "leave",
+				"user_id":    c.UserID,
+			},
+		}
+	}
+
+	// Gather the rooms safely WITHOUT calling RemovePeer yet
+	var activeRooms []*Room
+
+	Manager.mu.RLock()
+	for _, room := range Manager.Rooms {
+		room.mu.RLock()
+		_, exists := room.Peers[c.UserID]
+		room.mu.RUnlock()
+
+		if exists {
+			activeRooms = append(activeRooms, room)
+		}
+	}
+	Manager.mu.RUnlock() // Release the Manager lock entirely
+
+	// Now it is safe to remove the peer
+	for _, room := range activeRooms {
+		log.Printf("[Voice WS] Removing User %d from Room %d", c.UserID, room.ID)
+		room.RemovePeer(c.UserID)
+	}
+}
+
+// DisconnectFromServer kicks a user out of any voice channel they occupy in a server.
+// Used by moderation so kicked, banned or timed out users lose their audio immediately.
+func DisconnectFromServer(userID uint64, serverID uint64) {
+	VoiceRegistry.RLock()
+	client, exists := VoiceRegistry.Clients[userID]
+	VoiceRegistry.RUnlock()
+
+	if !exists {
+		return
+	}
+
+	// Their own disconnect may be racing this one, only the first gets the channel
+	channelID, _ := client.takeActive(serverID)
+	if channelID == 0 {
+		return
+	}
+	client.leave(channelID, serverID)
+
+	// Let their client know so it can tear down its side of the connection.
+	// If the write pump is gone or backed up they're disconnected anyway, don't hang the request on it.
+	select {
+	case client.Send <- websockets.WsMessage{
+		TargetChannelID: channelID,
+		Event:           "VOICE_DISCONNECT",
+		Data:            map[string]interface{}{"reason": "removed by a moderator"},
+	}:
+	default:
+	}
+}
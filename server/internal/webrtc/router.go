@@ -10,6 +10,7 @@ import (
 
 	"github.com/jonahgcarpenter/hermes/server/internal/database"
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
 	"github.com/jonahgcarpenter/hermes/server/internal/websockets"
 )
 
@@ -43,15 +44,25 @@ func handleOffer(c *VoiceClient, msg websockets.WsMessage) {
 
 	// Fetch Server ID to know who to broadcast to
 	var channel models.Channel
-	database.DB.Select("server_id").Where("id = ?", msg.TargetChannelID).First(&channel)
+	database.DB.Select("server_id", "type").Where("id = ?", msg.TargetChannelID).First(&channel)
+
+	// Make sure this user is allowed to connect to this voice channel (DMs have no voice)
+	if channel.ServerID == nil || channel.Type != models.ChannelTypeVoice || !canJoinVoice(c.UserID, *channel.ServerID, msg.TargetChannelID) {
+		log.Printf("[WebRTC Router] User %d is not allowed to join voice channel %d", c.UserID, msg.TargetChannelID)
+		c.Send <- websockets.WsMessage{
+			TargetChannelID: msg.TargetChannelID,
+			Event:           "VOICE_ERROR",
+			Data:            map[string]interface{}{"error": "You do not have permission to join this voice channel"},
+		}
+		return
+	}
 
 	// Save this context to the client so we can use it when they disconnect
-	c.ActiveChannelID = msg.TargetChannelID
-	c.ActiveServerID = channel.ServerID
+	c.setActive(msg.TargetChannelID, *channel.ServerID)
 
 	// Broadcast the JOIN event to the Global Hub
 	websockets.Manager.Broadcast <- websockets.WsMessage{
-		TargetServerID: channel.ServerID,
+		TargetServerID: *channel.ServerID,
 		Event:          "VOICE_STATE_UPDATE",
 		Data: map[string]interface{}{
 			"channel_id": fmt.Sprintf("%d", msg.TargetChannelID),
@@ -154,3 +165,16 @@ func handleIceCandidate(c *VoiceClient, msg websockets.WsMessage) {
 		log.Printf("[WebRTC Warning] Received ICE candidate but no PC found for user %d", c.UserID)
 	}
 }
+
+// Checks voice permissions after channel overwrites are applied
+func canJoinVoice(userID uint64, serverID uint64, channelID uint64) bool {
+	member, err := permissions.Resolve(serverID, userID)
+	if err != nil {
+		return false
+	}
+	perms, err := member.ForChannel(channelID)
+	if err != nil {
+		return false
+	}
+	return perms.Has(models.PermissionConnectVoice)
+}
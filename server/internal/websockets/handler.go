@@ -28,6 +28,7 @@ func ServeGlobalWS(c *gin.Context) {
 	var userServers []uint64
 	err := database.DB.Model(&models.ServerMember{}).
 		Where("user_id = ? AND left_at IS NULL", userID).
+		Where("server_id IN (?)", database.DB.Model(&models.Server{}).Select("id")).
 		Pluck("server_id", &userServers).Error
 
 	if err != nil {
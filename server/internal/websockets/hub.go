@@ -8,6 +8,9 @@ import (
 	"github.com/jonahgcarpenter/hermes/server/internal/models"
 )
 
+// How long a user can be disconnected before they count as offline
+const offlineGrace = 60 * time.Second
+
 type OfflineRequest struct {
 	UserID    uint64
 	ServerIDs []uint64
@@ -18,6 +21,22 @@ type WsMessage struct {
 	TargetChannelID uint64      `json:"channel_id,string,omitempty"`
 	Event           string      `json:"event"`
 	Data            interface{} `json:"data"`
+
+	// When set, only these users receive the message (used for private channels)
+	VisibleTo map[uint64]bool `json:"-"`
+
+	// Sends straight to these users' connections instead of a server room.
+	// Used for DMs and for events aimed at specific people, like mentions.
+	TargetUserIDs []uint64 `json:"-"`
+
+	// Sends to this one connection only, for replies to something it sent
+	TargetClient *Client `json:"-"`
+}
+
+// OnlineQuery asks the Run loop which of a set of users have a live connection
+type OnlineQuery struct {
+	UserIDs []uint64
+	Reply   chan map[uint64]bool
 }
 
 type RoomUpdate struct {
@@ -35,6 +54,7 @@ type Hub struct {
 	LeaveRoom       chan RoomUpdate
 	OfflineTimers   map[uint64]*time.Timer
 	FinalizeOffline chan OfflineRequest
+	Online          chan OnlineQuery
 }
 
 var Manager = Hub{
@@ -47,14 +67,98 @@ var Manager = Hub{
 	LeaveRoom:       make(chan RoomUpdate),
 	OfflineTimers:   make(map[uint64]*time.Timer),
 	FinalizeOffline: make(chan OfflineRequest),
+	Online:          make(chan OnlineQuery),
+}
+
+// OnlineUsers returns the subset of userIDs with at least one open connection.
+// Safe to call from anywhere, the lookup itself happens inside Run().
+func (h *Hub) OnlineUsers(userIDs []uint64) map[uint64]bool {
+	reply := make(chan map[uint64]bool, 1)
+	h.Online <- OnlineQuery{UserIDs: userIDs, Reply: reply}
+	return <-reply
+}
+
+// Non-blocking send to a single connection, only called from the Run() loop
+func (h *Hub) deliver(client *Client, msg WsMessage) {
+	select {
+	case client.Send <- msg:
+		// Successfully pushed to the client's send buffer
+	default:
+		// The client's buffer is full (dead or stuck connection).
+		// Close the channel. The writePump will error out,
+		// close the socket, and trigger the Unregister flow cleanly.
+		close(client.Send)
+
+		// Remove them from the routing maps immediately to prevent retries
+		for _, serverID := range client.ServerIDs {
+			delete(h.ServerRooms[serverID], client)
+		}
+		if _, userOk := h.Clients[client.UserID][client]; userOk {
+			delete(h.Clients[client.UserID], client)
+		}
+	}
+}
+
+// Ends a user's temporary memberships in these servers, only called from the Run() loop
+func (h *Hub) endTemporaryMemberships(userID uint64, serverIDs []uint64) {
+	if len(serverIDs) == 0 {
+		return
+	}
+	database.DB.Model(&models.ServerMember{}).
+		Where("user_id = ? AND server_id IN ?", userID, serverIDs).
+		Update("left_at", time.Now())
+
+	for _, serverID := range serverIDs {
+		removeMsg := WsMessage{
+			TargetServerID: serverID,
+			Event:          "SERVER_MEMBER_REMOVE",
+			Data:           map[string]interface{}{"user_id": fmt.Sprintf("%d", userID)},
+		}
+		go func(msg WsMessage) {
+			h.Broadcast <- msg
+		}(removeMsg)
+	}
+}
+
+// Catches temporary members the offline timer never fired for, because their
+// socket never connected or the server restarted while they were online.
+// Anyone connected or still inside their grace period is left alone.
+func (h *Hub) sweepTemporaryMembers() {
+	var members []models.ServerMember
+	database.DB.Select("server_id", "user_id").
+		Where("temporary = ? AND left_at IS NULL AND joined_at < ?", true, time.Now().Add(-offlineGrace)).
+		Find(&members)
+
+	stale := make(map[uint64][]uint64)
+	for _, member := range members {
+		if len(h.Clients[member.UserID]) > 0 {
+			continue
+		}
+		if _, pending := h.OfflineTimers[member.UserID]; pending {
+			continue
+		}
+		stale[member.UserID] = append(stale[member.UserID], member.ServerID)
+	}
+	for userID, serverIDs := range stale {
+		h.endTemporaryMemberships(userID, serverIDs)
+	}
 }
 
 // Run starts an infinite loop that listens for activity on the Hub's channels.
 // This runs in its own background goroutine (started in main.go).
 func (h *Hub) Run() {
+	// The first sweep lands one grace period after startup, so everyone who was
+	// online before a restart gets the same time to reconnect as a normal drop
+	sweep := time.NewTicker(offlineGrace)
+	defer sweep.Stop()
+
 	for {
 		select {
 
+		// Stale temporary memberships
+		case <-sweep.C:
+			h.sweepTemporaryMembers()
+
 		// Client Connected
 		case client := <-h.Register:
 			// Cancel pending offline status
@@ -136,7 +240,7 @@ func (h *Hub) Run() {
 					serverIDs := append([]uint64(nil), client.ServerIDs...)
 
 					// Start a 60-second timer
-					timer := time.AfterFunc(60*time.Second, func() {
+					timer := time.AfterFunc(offlineGrace, func() {
 						// Send the request back to the thread-safe Hub loop
 						h.FinalizeOffline <- OfflineRequest{
 							UserID:    userID,
@@ -166,6 +270,13 @@ func (h *Hub) Run() {
 
 				database.DB.Model(&models.User{}).Where("id = ?", req.UserID).Update("status", "offline")
 
+				// Temporary memberships from invites end once the user goes offline
+				var temporaryServerIDs []uint64
+				database.DB.Model(&models.ServerMember{}).
+					Where("user_id = ? AND temporary = ? AND left_at IS NULL", req.UserID, true).
+					Pluck("server_id", &temporaryServerIDs)
+				h.endTemporaryMemberships(req.UserID, temporaryServerIDs)
+
 				for _, serverID := range req.ServerIDs {
 					offlineMsg := WsMessage{
 						TargetServerID: serverID,
@@ -186,28 +297,45 @@ func (h *Hub) Run() {
 
 		// Broadcast triggered by HTTP Controllers or Internal Events
 		case msg := <-h.Broadcast:
+			// It may have disconnected while the reply was queued
+			if msg.TargetClient != nil {
+				if h.Clients[msg.TargetClient.UserID][msg.TargetClient] {
+					h.deliver(msg.TargetClient, msg)
+				}
+				continue
+			}
+
+			// DMs and targeted events fan out to every connection of each listed user
+			if msg.TargetServerID == 0 || len(msg.TargetUserIDs) > 0 {
+				for _, userID := range msg.TargetUserIDs {
+					for client := range h.Clients[userID] {
+						h.deliver(client, msg)
+					}
+				}
+				continue
+			}
+
 			// Get the Set of connected clients for this Server
 			if roomConns, ok := h.ServerRooms[msg.TargetServerID]; ok {
 				// Iterate through only the clients who need this message
 				for client := range roomConns {
-					// Non-blocking send
-					select {
-					case client.Send <- msg:
-						// Successfully pushed to the client's send buffer
-					default:
-						// The client's buffer is full (dead or stuck connection).
-						// Close the channel. The writePump will error out,
-						// close the socket, and trigger the Unregister flow cleanly.
-						close(client.Send)
-
-						// Remove them from the routing maps immediately to prevent retries
-						delete(h.ServerRooms[msg.TargetServerID], client)
-						if _, userOk := h.Clients[client.UserID][client]; userOk {
-							delete(h.Clients[client.UserID], client)
-						}
+					// Skip users who can't see the channel this event belongs to
+					if msg.VisibleTo != nil && !msg.VisibleTo[client.UserID] {
+						continue
 					}
+					h.deliver(client, msg)
 				}
 			}
+		// Presence lookups from outside the loop
+		case query := <-h.Online:
+			online := make(map[uint64]bool)
+			for _, userID := range query.UserIDs {
+				if len(h.Clients[userID]) > 0 {
+					online[userID] = true
+				}
+			}
+			query.Reply <- online
+
 		// User joins a new server
 		case req := <-h.JoinRoom:
 			// Check if this user currently has any active connections
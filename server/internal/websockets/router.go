@@ -1,15 +1,125 @@
 package websockets
 
-import "log"
+import (
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/jonahgcarpenter/hermes/server/internal/database"
+	"github.com/jonahgcarpenter/hermes/server/internal/models"
+	"github.com/jonahgcarpenter/hermes/server/internal/permissions"
+	"github.com/jonahgcarpenter/hermes/server/internal/ratelimit"
+	"github.com/jonahgcarpenter/hermes/server/internal/readstates"
+)
 
 // RouteMessage acts as the traffic controller for all incoming websocket JSON
 func RouteMessage(c *Client, msg WsMessage) {
+	// Same budget per user however many connections they have open. Only the sender hears about it.
+	if ok, retryAfter := ratelimit.Events.Allow(strconv.FormatUint(c.UserID, 10)); !ok {
+		Manager.Broadcast <- WsMessage{
+			Event: "RATE_LIMITED",
+			Data: map[string]interface{}{
+				"event":       msg.Event,
+				"retry_after": math.Ceil(retryAfter.Seconds()*1000) / 1000,
+			},
+			TargetClient: c,
+		}
+		return
+	}
+
 	switch msg.Event {
 	case "TYPING_START": //
-		// We trust the client's payload and just fan it out to the room.
+		// DMs have no server, just check they're in the conversation and fan out to the others in it
+		if msg.TargetServerID == 0 {
+			if !permissions.IsDMRecipient(msg.TargetChannelID, c.UserID) {
+				return
+			}
+			recipients, err := permissions.DMRecipients(msg.TargetChannelID)
+			if err != nil {
+				log.Printf("Failed to resolve DM recipients for typing event: %v", err)
+				return
+			}
+			msg.TargetUserIDs = recipients
+			Manager.Broadcast <- msg
+			return
+		}
+
+		// Only members who can talk in the channel may announce typing.
+		// This also stops timed out members and spoofed server IDs.
+		if !hasChannelPermission(c.UserID, msg.TargetServerID, msg.TargetChannelID, models.PermissionSendMessages) {
+			return
+		}
+
+		// Fan it out to the room, limited to the people who can actually see the channel.
+		viewers, err := permissions.ChannelViewers(msg.TargetServerID, msg.TargetChannelID)
+		if err != nil {
+			log.Printf("Failed to resolve channel viewers for typing event: %v", err)
+			return
+		}
+		msg.VisibleTo = viewers
 		Manager.Broadcast <- msg
 
+	case "MESSAGE_ACK":
+		// Same as the ack endpoint, for clients that would rather not make a request per channel switch
+		data, ok := msg.Data.(map[string]interface{})
+		if !ok {
+			return
+		}
+		raw, _ := data["message_id"].(string)
+		messageID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return
+		}
+
+		if msg.TargetServerID == 0 {
+			if !permissions.IsDMRecipient(msg.TargetChannelID, c.UserID) {
+				return
+			}
+		} else if !hasChannelPermission(c.UserID, msg.TargetServerID, msg.TargetChannelID, models.PermissionViewChannels) {
+			return
+		}
+
+		var count int64
+		database.DB.Model(&models.Message{}).Where("id = ? AND channel_id = ?", messageID, msg.TargetChannelID).Count(&count)
+		if count == 0 {
+			return
+		}
+
+		state, err := readstates.Ack(c.UserID, msg.TargetChannelID, messageID)
+		if err != nil {
+			log.Printf("Failed to ack message %d for user %d: %v", messageID, c.UserID, err)
+			return
+		}
+
+		// Echo it to every session, including this one, so they all agree on the marker
+		Manager.Broadcast <- WsMessage{
+			TargetServerID:  msg.TargetServerID,
+			TargetChannelID: msg.TargetChannelID,
+			Event:           "MESSAGE_ACK",
+			Data:            state,
+			TargetUserIDs:   []uint64{c.UserID},
+		}
+
 	default:
 		log.Printf("Unknown event type received: %s", msg.Event)
 	}
 }
+
+// Checks a channel permission after overwrites are applied
+func hasChannelPermission(userID uint64, serverID uint64, channelID uint64, perm models.Permission) bool {
+	var count int64
+	database.DB.Model(&models.Channel{}).Where("id = ? AND server_id = ?", channelID, serverID).Count(&count)
+	if count == 0 {
+		return false
+	}
+
+	member, err := permissions.Resolve(serverID, userID)
+	if err != nil {
+		return false
+	}
+	perms, err := member.ForChannel(channelID)
+	if err != nil {
+		return false
+	}
+	return perms.Has(perm)
+}